@@ -0,0 +1,108 @@
+package boltstoretest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yosssi/boltstore/shared"
+)
+
+// Clock represents a fake clock whose time moves only when it is told to.
+// It can be used as store.Config.Clock and reaper.Options.Clock. Its timers
+// fire when the clock is moved past their time.
+type Clock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+}
+
+// NewClock creates and returns a clock which shows the given time.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by the duration.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+// Set sets the time of the clock.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+	c.fire()
+}
+
+// NewTimer creates a timer which fires when the clock is moved
+// by the duration.
+func (c *Clock) NewTimer(d time.Duration) shared.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &timer{clock: c, c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	t.reset(d)
+	return t
+}
+
+// fire fires the active timers whose time has come.
+// The caller must hold c.mu.
+func (c *Clock) fire() {
+	for _, t := range c.timers {
+		if t.active && !t.at.After(c.now) {
+			t.active = false
+			select {
+			case t.c <- c.now:
+			default:
+			}
+		}
+	}
+}
+
+// timer represents a timer of a fake clock.
+type timer struct {
+	clock  *Clock
+	c      chan time.Time
+	at     time.Time
+	active bool
+}
+
+// C returns the channel on which the time is sent.
+func (t *timer) C() <-chan time.Time {
+	return t.c
+}
+
+// Reset changes the timer to fire when the clock is moved by the duration.
+func (t *timer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.reset(d)
+}
+
+// reset changes the timer. The caller must hold t.clock.mu.
+func (t *timer) reset(d time.Duration) bool {
+	active := t.active
+	t.at = t.clock.now.Add(d)
+	t.active = true
+	t.clock.fire()
+	return active
+}
+
+// Stop prevents the timer from firing.
+func (t *timer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.active
+	t.active = false
+	return active
+}
@@ -0,0 +1,60 @@
+package boltstoretest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := NewClock(now)
+	if !c.Now().Equal(now) {
+		t.Errorf("c.Now() should return %v (actual: %v)", now, c.Now())
+	}
+	c.Advance(time.Minute)
+	if expected := now.Add(time.Minute); !c.Now().Equal(expected) {
+		t.Errorf("c.Now() should return %v (actual: %v)", expected, c.Now())
+	}
+	c.Set(now)
+	if !c.Now().Equal(now) {
+		t.Errorf("c.Now() should return %v (actual: %v)", now, c.Now())
+	}
+}
+
+func TestClock_NewTimer(t *testing.T) {
+	c := NewClock(time.Unix(1000, 0))
+	timer := c.NewTimer(time.Minute)
+	fired := func() bool {
+		select {
+		case <-timer.C():
+			return true
+		default:
+			return false
+		}
+	}
+
+	c.Advance(59 * time.Second)
+	if fired() {
+		t.Error("the timer should not fire before its time")
+	}
+	c.Advance(time.Second)
+	if !fired() {
+		t.Error("the timer should fire at its time")
+	}
+
+	if timer.Reset(time.Minute) {
+		t.Error("timer.Reset() should return false for the fired timer")
+	}
+	if !timer.Stop() {
+		t.Error("timer.Stop() should return true for the active timer")
+	}
+	c.Advance(time.Hour)
+	if fired() {
+		t.Error("the stopped timer should not fire")
+	}
+
+	timer.Reset(0)
+	if !fired() {
+		t.Error("the timer should fire immediately for a zero duration")
+	}
+}
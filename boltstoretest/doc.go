@@ -0,0 +1,7 @@
+/*
+Package boltstoretest provides utilities for testing code which uses
+BoltStore: a store backed by a temporary database file, a fake clock
+to test expiration without sleeping, requests carrying session IDs
+through the transport of the store and assertions on stored session data.
+*/
+package boltstoretest
@@ -0,0 +1,205 @@
+package boltstoretest
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/yosssi/boltstore/store"
+)
+
+// KeyPairs are the key pairs used when none is given to NewStore.
+var KeyPairs = [][]byte{[]byte("boltstoretest-secret-key")}
+
+// Store represents a session store backed by a temporary database file
+// which is removed when the test finishes.
+type Store struct {
+	*store.Store
+	// Clock is the fake clock of the store. It is nil if the config
+	// has another kind of clock.
+	Clock *Clock
+
+	t         testing.TB
+	codecs    []securecookie.Codec
+	transport store.Transport
+}
+
+// NewStore creates and returns a store backed by a temporary database
+// file. A fake clock showing the current time is used if the config has
+// no clock. KeyPairs are used if no key pair is given.
+func NewStore(t testing.TB, config store.Config, keyPairs ...[]byte) *Store {
+	t.Helper()
+	f, err := ioutil.TempFile("", "boltstoretest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	path := f.Name()
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		os.Remove(path)
+		t.Fatal(err)
+	}
+
+	if config.Clock == nil {
+		config.Clock = NewClock(time.Now())
+	}
+	clock, _ := config.Clock.(*Clock)
+	if len(keyPairs) == 0 {
+		keyPairs = KeyPairs
+	}
+	transport := config.Transport
+	if transport == nil {
+		transport = store.CookieTransport{}
+	}
+	str, err := store.New(db, config, keyPairs...)
+	if err != nil {
+		db.Close()
+		os.Remove(path)
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		// The database may have been replaced by compaction.
+		str.DB().Close()
+		os.Remove(path)
+	})
+
+	return &Store{
+		Store:     str,
+		Clock:     clock,
+		t:         t,
+		codecs:    securecookie.CodecsFromPairs(keyPairs...),
+		transport: transport,
+	}
+}
+
+// Request returns a request which carries the session ID encoded with
+// the key pairs of the store through the transport of the store.
+func (s *Store) Request(name, id string) *http.Request {
+	s.t.Helper()
+	r := httptest.NewRequest("GET", "http://localhost/", nil)
+	if id == "" {
+		return r
+	}
+	encoded, err := securecookie.EncodeMulti(name, id, s.codecs...)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	carry(r, s.transport, name, encoded)
+	if value, ok := s.transport.Get(r, name); !ok || value != encoded {
+		s.t.Fatalf("the transport cannot carry session %s in a request", name)
+	}
+	return r
+}
+
+// carry makes the request carry the value which the transport sends
+// in a response. Cookies are sent back as cookies and other headers
+// as they are, except that a bearer token is sent in the Authorization
+// header.
+func carry(r *http.Request, transport store.Transport, name, value string) {
+	switch t := transport.(type) {
+	case store.BearerTransport:
+		r.Header.Set("Authorization", "Bearer "+value)
+	case store.MultiTransport:
+		for _, u := range t {
+			carry(r, u, name, value)
+		}
+	default:
+		w := httptest.NewRecorder()
+		transport.Set(w, name, value, &sessions.Options{Path: "/"})
+		for _, c := range w.Result().Cookies() {
+			r.AddCookie(c)
+		}
+		for k, v := range w.Header() {
+			if k != "Set-Cookie" {
+				r.Header[k] = v
+			}
+		}
+	}
+}
+
+// SaveValues creates and saves a session which has the values,
+// and returns the session.
+func (s *Store) SaveValues(name string, values map[interface{}]interface{}) *sessions.Session {
+	s.t.Helper()
+	r := s.Request(name, "")
+	session, err := s.Store.New(r, name)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	for k, v := range values {
+		session.Values[k] = v
+	}
+	if err := s.Store.Save(r, httptest.NewRecorder(), session); err != nil {
+		s.t.Fatal(err)
+	}
+	return session
+}
+
+// Load loads the session of the given name carried by the request.
+func (s *Store) Load(r *http.Request, name string) *sessions.Session {
+	s.t.Helper()
+	session, err := s.Store.New(r, name)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return session
+}
+
+// AssertStored checks if the session data of the ID is stored.
+func (s *Store) AssertStored(id string) {
+	s.t.Helper()
+	if _, ok, err := s.Lookup(id); err != nil || !ok {
+		s.t.Errorf("session %s should be stored (actual: %t, %v)", id, ok, err)
+	}
+}
+
+// AssertNotStored checks if the session data of the ID is not stored.
+func (s *Store) AssertNotStored(id string) {
+	s.t.Helper()
+	if _, ok, err := s.Lookup(id); err != nil || ok {
+		s.t.Errorf("session %s should not be stored (actual: %t, %v)", id, ok, err)
+	}
+}
+
+// AssertExpiresAt checks if the session data of the ID expires
+// at the given time.
+func (s *Store) AssertExpiresAt(id string, expiresAt time.Time) {
+	s.t.Helper()
+	session, ok, err := s.Lookup(id)
+	if err != nil || !ok {
+		s.t.Errorf("session %s should be stored (actual: %t, %v)", id, ok, err)
+		return
+	}
+	if session.GetExpiresAt() != expiresAt.Unix() {
+		s.t.Errorf("session %s should expire at %v (actual: %v)", id, expiresAt, time.Unix(session.GetExpiresAt(), 0))
+	}
+}
+
+// AssertValue checks if the stored session values of the ID has the value
+// of the key.
+func (s *Store) AssertValue(id string, key, value interface{}) {
+	s.t.Helper()
+	session, ok, err := s.Lookup(id)
+	if err != nil || !ok {
+		s.t.Errorf("session %s should be stored (actual: %t, %v)", id, ok, err)
+		return
+	}
+	values := make(map[interface{}]interface{})
+	if err := gob.NewDecoder(bytes.NewReader(session.Values)).Decode(&values); err != nil {
+		s.t.Errorf("session %s should have valid values (actual: %v)", id, err)
+		return
+	}
+	if actual, ok := values[key]; !ok || !reflect.DeepEqual(actual, value) {
+		s.t.Errorf("session %s should have %#v: %#v (actual: %#v)", id, key, value, actual)
+	}
+}
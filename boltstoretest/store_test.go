@@ -0,0 +1,133 @@
+package boltstoretest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/yosssi/boltstore/shared"
+	"github.com/yosssi/boltstore/store"
+)
+
+// recorder records failures instead of reporting them.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestNewStore(t *testing.T) {
+	s := NewStore(t, store.Config{})
+	if s.Clock == nil {
+		t.Error("s.Clock should be set")
+	}
+	path := s.DB().Path()
+	if path == "" {
+		t.Error("the database should be open")
+	}
+
+	// When the config has another clock
+	s = NewStore(t, store.Config{Clock: shared.SystemClock{}}, []byte("secret-key"))
+	if s.Clock != nil {
+		t.Errorf("s.Clock should be nil (actual: %+v)", s.Clock)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore(t, store.Config{})
+	session := s.SaveValues("test", map[interface{}]interface{}{"key": "value"})
+
+	loaded := s.Load(s.Request("test", session.ID), "test")
+	if loaded.IsNew || loaded.Values["key"] != "value" {
+		t.Errorf("the session should be loaded (actual: %+v)", loaded.Values)
+	}
+	s.AssertStored(session.ID)
+	s.AssertValue(session.ID, "key", "value")
+	s.AssertExpiresAt(session.ID, s.Clock.Now().Add(time.Duration(shared.DefaultMaxAge)*time.Second))
+
+	// When the session is expired
+	s.Clock.Advance(time.Duration(shared.DefaultMaxAge) * time.Second)
+	if loaded := s.Load(s.Request("test", session.ID), "test"); !loaded.IsNew {
+		t.Error("the expired session should not be loaded")
+	}
+	s.AssertNotStored(session.ID)
+}
+
+// Store should still be a sessions.Store.
+var _ sessions.Store = (*Store)(nil)
+
+func TestStore_Request(t *testing.T) {
+	for _, transport := range []store.Transport{
+		nil,
+		store.HeaderTransport{Header: "X-Session"},
+		store.BearerTransport{},
+		store.MultiTransport{store.BearerTransport{Name: "other"}, store.CookieTransport{}},
+	} {
+		s := NewStore(t, store.Config{Transport: transport})
+		session := s.SaveValues("test", map[interface{}]interface{}{"key": "value"})
+		if loaded := s.Load(s.Request("test", session.ID), "test"); loaded.IsNew {
+			t.Errorf("the session should be loaded through %#v", transport)
+		}
+	}
+}
+
+func TestStore_clockSkew(t *testing.T) {
+	s := NewStore(t, store.Config{ClockSkew: time.Minute})
+	session := s.SaveValues("test", nil)
+	maxAge := time.Duration(shared.DefaultMaxAge) * time.Second
+
+	// When the session is expired within the grace period
+	s.Clock.Advance(maxAge + 59*time.Second)
+	if loaded := s.Load(s.Request("test", session.ID), "test"); loaded.IsNew {
+		t.Error("the session should be loaded within the grace period")
+	}
+
+	// When the grace period passes
+	s.Clock.Advance(time.Second)
+	if loaded := s.Load(s.Request("test", session.ID), "test"); !loaded.IsNew {
+		t.Error("the session should not be loaded after the grace period")
+	}
+	s.AssertNotStored(session.ID)
+}
+
+func TestStore_assertionFailures(t *testing.T) {
+	s := NewStore(t, store.Config{})
+	session := s.SaveValues("test", map[interface{}]interface{}{"key": "value"})
+
+	r := &recorder{TB: t}
+	s.t = r
+	s.AssertStored("none")
+	s.AssertNotStored(session.ID)
+	s.AssertExpiresAt("none", time.Now())
+	s.AssertExpiresAt(session.ID, time.Unix(0, 0))
+	s.AssertValue("none", "key", "value")
+	s.AssertValue(session.ID, "key", "other")
+	s.AssertValue(session.ID, "none", "value")
+	if len(r.failures) != 7 {
+		t.Errorf("7 assertions should fail (actual: %q)", r.failures)
+	}
+}
+
+func ExampleNewStore() {
+	testExpiration := func(t *testing.T) {
+		// Create a store with a fake clock.
+		s := NewStore(t, store.Config{})
+
+		// Save a session and make a request which carries its cookie.
+		session := s.SaveValues("session-key", map[interface{}]interface{}{"user": "alice"})
+		s.AssertValue(session.ID, "user", "alice")
+
+		// Let the session expire without sleeping.
+		s.Clock.Advance(time.Duration(shared.DefaultMaxAge) * time.Second)
+		if loaded := s.Load(s.Request("session-key", session.ID), "session-key"); !loaded.IsNew {
+			t.Error("the session should be expired")
+		}
+	}
+	_ = testExpiration
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/yosssi/boltstore/reaper"
+	"github.com/yosssi/boltstore/shared"
+	"github.com/yosssi/boltstore/store"
+)
+
+// sessionName is the name of the sessions of the workload.
+const sessionName = "bench"
+
+// defaultMix is the default weights of the operations.
+const defaultMix = "new=10,load=70,save=15,delete=5"
+
+// keyPair is the securecookie key pair of the store.
+var keyPair = []byte("boltstore-bench-secret-key")
+
+// op represents an operation of the workload.
+type op int
+
+// Operations of the workload
+const (
+	opNew op = iota
+	opLoad
+	opSave
+	opDelete
+	numOps
+)
+
+// opNames are the names of the operations.
+var opNames = [numOps]string{"new", "load", "save", "delete"}
+
+// mix represents the weights of the operations.
+type mix [numOps]int
+
+// parseMix parses comma-separated name=weight pairs. Operations which
+// are not listed have no weight.
+func parseMix(s string) (mix, error) {
+	var m mix
+	var total int
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return m, fmt.Errorf("boltstore-bench: invalid mix %q", pair)
+		}
+		o := -1
+		for i, name := range opNames {
+			if name == kv[0] {
+				o = i
+			}
+		}
+		if o < 0 {
+			return m, fmt.Errorf("boltstore-bench: unknown operation %q", kv[0])
+		}
+		w, err := strconv.Atoi(kv[1])
+		if err != nil || w < 0 {
+			return m, fmt.Errorf("boltstore-bench: invalid weight %q", kv[1])
+		}
+		m[o] = w
+		total += w
+	}
+	if total == 0 {
+		return m, fmt.Errorf("boltstore-bench: mix %q has no weight", s)
+	}
+	return m, nil
+}
+
+// pick chooses an operation at random in proportion to the weights.
+func (m mix) pick(rnd *rand.Rand) op {
+	var total int
+	for _, w := range m {
+		total += w
+	}
+	n := rnd.Intn(total)
+	for o, w := range m {
+		if n < w {
+			return op(o)
+		}
+		n -= w
+	}
+	return opNew
+}
+
+// config represents the settings of the workload.
+type config struct {
+	duration     time.Duration
+	ops          int
+	concurrency  int
+	mix          mix
+	size         int
+	maxAge       int
+	preload      int
+	partition    time.Duration
+	reapInterval time.Duration
+	reapBatch    int
+	reapIndex    bool
+	noSync       bool
+	path         string
+}
+
+// pool represents the IDs of the saved sessions.
+type pool struct {
+	mu  sync.Mutex
+	ids []string
+	idx map[string]int
+}
+
+// add adds the ID to the pool.
+func (p *pool) add(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.idx[id]; ok {
+		return
+	}
+	p.idx[id] = len(p.ids)
+	p.ids = append(p.ids, id)
+}
+
+// remove removes the ID from the pool.
+func (p *pool) remove(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i, ok := p.idx[id]
+	if !ok {
+		return
+	}
+	last := p.ids[len(p.ids)-1]
+	p.ids[i] = last
+	p.idx[last] = i
+	p.ids = p.ids[:len(p.ids)-1]
+	delete(p.idx, id)
+}
+
+// random returns an ID chosen at random or "" if the pool is empty.
+func (p *pool) random(rnd *rand.Rand) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.ids) == 0 {
+		return ""
+	}
+	return p.ids[rnd.Intn(len(p.ids))]
+}
+
+// result represents the result of the workload.
+type result struct {
+	elapsed   time.Duration
+	latencies [numOps][]time.Duration
+	errors    [numOps]int
+	startSize int64
+	endSize   int64
+	sessions  int
+	reaper    *reaper.Stats
+}
+
+// bench represents a running workload.
+type bench struct {
+	config  config
+	store   *store.Store
+	codecs  []securecookie.Codec
+	pool    *pool
+	payload []byte
+	count   int64
+}
+
+// run sets up the database and the store, runs the workload and
+// returns its result.
+func run(c config) (*result, error) {
+	path := c.path
+	if path == "" {
+		f, err := ioutil.TempFile("", "boltstore-bench")
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		path = f.Name()
+		defer os.Remove(path)
+	}
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	db.NoSync = c.noSync
+
+	str, err := store.New(db, store.Config{
+		SessionOptions: sessions.Options{MaxAge: c.maxAge},
+		DBOptions:      store.Options{Partition: c.partition},
+	}, keyPair)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &bench{
+		config:  c,
+		store:   str,
+		codecs:  securecookie.CodecsFromPairs(keyPair),
+		pool:    &pool{idx: make(map[string]int)},
+		payload: make([]byte, c.size),
+	}
+	rand.Read(b.payload)
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := 0; i < c.preload; i++ {
+		if err := b.do(opNew, rnd); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &result{startSize: fileSize(db)}
+
+	var r *reaper.Reaper
+	if c.reapInterval > 0 {
+		r = reaper.New(db, reaper.Options{
+			CheckInterval:  c.reapInterval,
+			BatchSize:      c.reapBatch,
+			UseExpiryIndex: c.reapIndex,
+			Partitioned:    c.partition > 0,
+		})
+		r.Start(context.Background())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.duration)
+	defer cancel()
+	start := time.Now()
+	workers := make([]*result, c.concurrency)
+	var wg sync.WaitGroup
+	for i := range workers {
+		workers[i] = &result{}
+		wg.Add(1)
+		go func(w *result, seed int64) {
+			defer wg.Done()
+			b.work(ctx, w, rand.New(rand.NewSource(seed)))
+		}(workers[i], time.Now().UnixNano()+int64(i))
+	}
+	wg.Wait()
+	res.elapsed = time.Since(start)
+
+	if r != nil {
+		if err := r.Stop(context.Background()); err != nil {
+			return nil, err
+		}
+		stats := r.Stats()
+		res.reaper = &stats
+	}
+
+	for _, w := range workers {
+		for o := range w.latencies {
+			res.latencies[o] = append(res.latencies[o], w.latencies[o]...)
+			res.errors[o] += w.errors[o]
+		}
+	}
+	res.endSize = fileSize(db)
+	err = db.View(func(tx *bolt.Tx) error {
+		res.sessions = countKeys(tx.Bucket([]byte(shared.DefaultBucketName)))
+		return nil
+	})
+	return res, err
+}
+
+// work runs operations until the context is done or the limit of
+// the number of operations is reached, recording them to the result.
+func (b *bench) work(ctx context.Context, res *result, rnd *rand.Rand) {
+	for ctx.Err() == nil {
+		if b.config.ops > 0 && atomic.AddInt64(&b.count, 1) > int64(b.config.ops) {
+			return
+		}
+		o := b.config.mix.pick(rnd)
+		start := time.Now()
+		err := b.do(o, rnd)
+		res.latencies[o] = append(res.latencies[o], time.Since(start))
+		if err != nil {
+			res.errors[o]++
+		}
+	}
+}
+
+// do runs the operation. An operation on a saved session creates
+// a new session while there is no saved session.
+func (b *bench) do(o op, rnd *rand.Rand) error {
+	id := ""
+	if o != opNew {
+		if id = b.pool.random(rnd); id == "" {
+			o = opNew
+		}
+	}
+
+	r, err := b.request(id)
+	if err != nil {
+		return err
+	}
+	session, err := b.store.New(r, sessionName)
+	if err != nil {
+		return err
+	}
+	if id != "" && session.IsNew {
+		// The session has expired or it has been deleted by another worker.
+		b.pool.remove(id)
+	}
+
+	switch o {
+	case opNew, opSave:
+		session.Values["data"] = b.payload
+		session.Values["n"] = rnd.Int()
+	case opLoad:
+		return nil
+	case opDelete:
+		session.Options.MaxAge = -1
+	}
+	if err := b.store.Save(r, httptest.NewRecorder(), session); err != nil {
+		return err
+	}
+	if o == opDelete {
+		b.pool.remove(session.ID)
+	} else {
+		b.pool.add(session.ID)
+	}
+	return nil
+}
+
+// request returns a request which carries the cookie of the session ID.
+func (b *bench) request(id string) (*http.Request, error) {
+	r := httptest.NewRequest("GET", "http://localhost/", nil)
+	if id == "" {
+		return r, nil
+	}
+	encoded, err := securecookie.EncodeMulti(sessionName, id, b.codecs...)
+	if err != nil {
+		return nil, err
+	}
+	r.AddCookie(&http.Cookie{Name: sessionName, Value: encoded})
+	return r, nil
+}
+
+// print writes the report of the result.
+func (res *result) print(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "op\tcount\terrors\tops/s\tp50\tp90\tp99\tmax\t")
+	var all []time.Duration
+	var errs int
+	for o := range res.latencies {
+		res.printRow(w, opNames[o], res.latencies[o], res.errors[o])
+		all = append(all, res.latencies[o]...)
+		errs += res.errors[o]
+	}
+	res.printRow(w, "total", all, errs)
+	w.Flush()
+
+	fmt.Fprintf(out, "elapsed: %v\n", res.elapsed.Round(time.Millisecond))
+	fmt.Fprintf(out, "sessions: %d\n", res.sessions)
+	fmt.Fprintf(out, "file size: %d -> %d bytes (%+d)\n", res.startSize, res.endSize, res.endSize-res.startSize)
+	if res.reaper != nil {
+		fmt.Fprintf(out, "reaper: %d scanned, %d deleted, %d corrupt\n", res.reaper.Scanned, res.reaper.Deleted, res.reaper.Corrupt)
+	}
+}
+
+// printRow writes a row of the latencies of an operation.
+func (res *result) printRow(w io.Writer, name string, latencies []time.Duration, errs int) {
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var throughput float64
+	if res.elapsed > 0 {
+		throughput = float64(len(latencies)) / res.elapsed.Seconds()
+	}
+	fmt.Fprintf(w, "%s\t%d\t%d\t%.0f\t%v\t%v\t%v\t%v\t\n", name, len(latencies), errs, throughput,
+		percentile(latencies, 0.5), percentile(latencies, 0.9), percentile(latencies, 0.99), percentile(latencies, 1))
+}
+
+// percentile returns the p-th percentile of the sorted durations
+// by the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.999999) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// fileSize returns the size of the database file.
+func fileSize(db *bolt.DB) int64 {
+	info, err := os.Stat(db.Path())
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// countKeys returns the number of keys in the bucket and its sub-buckets.
+func countKeys(b *bolt.Bucket) int {
+	if b == nil {
+		return 0
+	}
+	var n int
+	b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			n += countKeys(b.Bucket(k))
+		} else {
+			n++
+		}
+		return nil
+	})
+	return n
+}
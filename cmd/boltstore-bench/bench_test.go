@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseMix(t *testing.T) {
+	m, err := parseMix(defaultMix)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if expected := (mix{10, 70, 15, 5}); m != expected {
+		t.Errorf("parseMix should return %v (actual: %v)", expected, m)
+	}
+
+	for _, s := range []string{"new", "update=1", "new=x", "new=-1", "new=0,load=0"} {
+		if _, err := parseMix(s); err == nil {
+			t.Errorf("parseMix(%q) should return an error", s)
+		}
+	}
+}
+
+func Test_mix_pick(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	m := mix{0, 1, 0, 0}
+	for i := 0; i < 10; i++ {
+		if o := m.pick(rnd); o != opLoad {
+			t.Errorf("m.pick should return %v (actual: %v)", opLoad, o)
+		}
+	}
+}
+
+func Test_percentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i))
+	}
+	cases := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{0.5, 50},
+		{0.9, 90},
+		{0.99, 99},
+		{1, 100},
+		{0, 1},
+	}
+	for _, c := range cases {
+		if actual := percentile(sorted, c.p); actual != c.expected {
+			t.Errorf("percentile(%v) should return %v (actual: %v)", c.p, c.expected, actual)
+		}
+	}
+	if actual := percentile(nil, 0.5); actual != 0 {
+		t.Errorf("percentile should return 0 for no durations (actual: %v)", actual)
+	}
+}
+
+func Test_pool(t *testing.T) {
+	p := &pool{idx: make(map[string]int)}
+	rnd := rand.New(rand.NewSource(1))
+	if id := p.random(rnd); id != "" {
+		t.Errorf("p.random should return an empty string (actual: %q)", id)
+	}
+	p.add("a")
+	p.add("b")
+	p.add("a")
+	p.remove("a")
+	p.remove("c")
+	if id := p.random(rnd); id != "b" || len(p.ids) != 1 {
+		t.Errorf("the pool should contain only b (actual: %q)", p.ids)
+	}
+}
+
+func Test_run(t *testing.T) {
+	res, err := run(config{
+		duration:     time.Minute,
+		ops:          200,
+		concurrency:  4,
+		mix:          mix{10, 70, 15, 5},
+		size:         64,
+		maxAge:       1,
+		preload:      10,
+		reapInterval: 10 * time.Millisecond,
+		reapBatch:    10,
+		noSync:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for o, latencies := range res.latencies {
+		n += len(latencies)
+		if res.errors[o] != 0 {
+			t.Errorf("%s should not fail (actual: %d errors)", opNames[o], res.errors[o])
+		}
+	}
+	if n != 200 {
+		t.Errorf("%d operations should run (actual: %d)", 200, n)
+	}
+	if res.endSize == 0 || res.sessions == 0 || res.reaper == nil {
+		t.Errorf("the result should be complete (actual: %+v)", res)
+	}
+
+	var buf bytes.Buffer
+	res.print(&buf)
+	for _, s := range []string{"new", "load", "save", "delete", "total", "file size:", "reaper:"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("the report should contain %q (actual: %s)", s, buf.String())
+		}
+	}
+}
@@ -0,0 +1,38 @@
+/*
+Command boltstore-bench drives a BoltStore with a mix of session
+operations against a temporary database file and reports the throughput,
+the latency percentiles of each operation and the growth of the file.
+
+Usage:
+
+	boltstore-bench [flags]
+
+The operations are:
+
+	new     create and save a session
+	load    load a saved session
+	save    load a saved session, modify it and save it
+	delete  load a saved session and delete it
+
+load, save and delete fall back to new while there is no saved session.
+
+The flags are:
+
+	-duration     how long to run the workload (default 10s)
+	-ops          maximum number of operations; 0 means no limit
+	-concurrency  number of concurrent workers (default 8)
+	-mix          weights of the operations
+	              (default "new=10,load=70,save=15,delete=5")
+	-size         number of bytes of the value of each session (default 256)
+	-max-age      max age of sessions in seconds (default 86400)
+	-preload      number of sessions saved before the workload
+	-partition    period of the sub-buckets which contain sessions
+	              (store.Options.Partition)
+	-reap         interval of the reaper; 0 disables it
+	-reap-batch   batch size of the reaper (default 100)
+	-reap-index   make the reaper use the expiry index
+	-no-sync      open the database with NoSync
+	-db           path of the database file; a temporary file which is
+	              removed at the end is used if it is empty
+*/
+package main
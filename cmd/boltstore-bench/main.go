@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yosssi/boltstore/shared"
+)
+
+func main() {
+	var c config
+	flag.DurationVar(&c.duration, "duration", 10*time.Second, "how long to run the workload")
+	flag.IntVar(&c.ops, "ops", 0, "maximum number of operations; 0 means no limit")
+	flag.IntVar(&c.concurrency, "concurrency", 8, "number of concurrent workers")
+	mixFlag := flag.String("mix", defaultMix, "weights of the operations")
+	flag.IntVar(&c.size, "size", 256, "number of bytes of the value of each session")
+	flag.IntVar(&c.maxAge, "max-age", shared.DefaultMaxAge, "max age of sessions in seconds")
+	flag.IntVar(&c.preload, "preload", 0, "number of sessions saved before the workload")
+	flag.DurationVar(&c.partition, "partition", 0, "period of the sub-buckets which contain sessions (store.Options.Partition)")
+	flag.DurationVar(&c.reapInterval, "reap", 0, "interval of the reaper; 0 disables it")
+	flag.IntVar(&c.reapBatch, "reap-batch", shared.DefaultBatchSize, "batch size of the reaper")
+	flag.BoolVar(&c.reapIndex, "reap-index", false, "make the reaper use the expiry index")
+	flag.BoolVar(&c.noSync, "no-sync", false, "open the database with NoSync")
+	flag.StringVar(&c.path, "db", "", "path of the database file; a temporary file is used if it is empty")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() > 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	m, err := parseMix(*mixFlag)
+	if err != nil {
+		fatal(err)
+	}
+	c.mix = m
+	if c.concurrency < 1 {
+		fatal(fmt.Errorf("boltstore-bench: concurrency should be positive (actual: %d)", c.concurrency))
+	}
+
+	res, err := run(c)
+	if err != nil {
+		fatal(err)
+	}
+	res.print(os.Stdout)
+}
+
+// usage prints the usage of the command.
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: boltstore-bench [flags]")
+	flag.PrintDefaults()
+}
+
+// fatal prints the error and exits.
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
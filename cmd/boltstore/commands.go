@@ -0,0 +1,495 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/securecookie"
+	"github.com/yosssi/boltstore/compact"
+	"github.com/yosssi/boltstore/shared"
+	"github.com/yosssi/boltstore/shared/protobuf"
+	// Register the types of session values defined by the store to gob.
+	_ "github.com/yosssi/boltstore/store"
+)
+
+// errNotFound is returned when the target session does not exist.
+var errNotFound = errors.New("boltstore: session not found")
+
+// env represents an environment in which a command runs.
+type env struct {
+	db         *bolt.DB
+	bucketName []byte
+	layout     shared.Layout
+	hashKey    []byte
+	codecs     []securecookie.Codec
+	in         io.Reader
+	out        io.Writer
+}
+
+// record represents a session record which is exported or imported.
+// ID is the session ID, or the hex-encoded database key if IDs are hashed.
+type record struct {
+	ID        string `json:"id"`
+	ExpiresAt int64  `json:"expiresAt"`
+	CreatedAt int64  `json:"createdAt,omitempty"`
+	UserID    string `json:"userID,omitempty"`
+	Values    []byte `json:"values"`
+	// ParentID, Impersonator and ImpersonatedAt are set
+	// for impersonation sessions.
+	ParentID       string `json:"parentID,omitempty"`
+	Impersonator   string `json:"impersonator,omitempty"`
+	ImpersonatedAt int64  `json:"impersonatedAt,omitempty"`
+}
+
+// defaultTimeout is the default time to wait for the lock
+// of the database file.
+const defaultTimeout = 5 * time.Second
+
+// readOnlyCommands represents the commands which do not modify the database.
+var readOnlyCommands = map[string]bool{
+	"list":          true,
+	"show":          true,
+	"count":         true,
+	"stats":         true,
+	"export":        true,
+	"decode-cookie": true,
+	"quarantine":    true,
+}
+
+// commands maps command names to their functions.
+var commands = map[string]func(e *env, args []string) error{
+	"list":          list,
+	"show":          show,
+	"count":         count,
+	"stats":         stats,
+	"purge-expired": purgeExpired,
+	"delete":        del,
+	"export":        export,
+	"import":        imp,
+	"decode-cookie": decodeCookie,
+	"quarantine":    quarantine,
+	"restore":       restore,
+	"compact":       compactDB,
+}
+
+// list prints the ID, the expiration and the status of all sessions.
+func list(e *env, args []string) error {
+	return e.forEach(func(k, v []byte) error {
+		session, err := shared.Session(v)
+		if err != nil {
+			fmt.Fprintf(e.out, "%s\t-\tinvalid\t%d\n", e.formatKey(k), len(v))
+			return nil
+		}
+		fmt.Fprintf(e.out, "%s\t%s\t%s\t%d\n", e.formatKey(k), expiresAt(session), status(session), len(v))
+		return nil
+	})
+}
+
+// show prints the session of the given ID.
+func show(e *env, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: boltstore show <id>")
+	}
+	return e.show(args[0])
+}
+
+// count prints the number of sessions.
+func count(e *env, args []string) error {
+	var n int
+	err := e.forEach(func(k, v []byte) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.out, n)
+	return nil
+}
+
+// stats prints statistics about sessions.
+func stats(e *env, args []string) error {
+	var total, numExpired, invalid, size int
+	var oldest, newest int64
+	err := e.forEach(func(k, v []byte) error {
+		total++
+		size += len(v)
+		session, err := shared.Session(v)
+		if err != nil {
+			invalid++
+			return nil
+		}
+		if expired(session) {
+			numExpired++
+		}
+		if t := session.GetExpiresAt(); oldest == 0 || t < oldest {
+			oldest = t
+		}
+		if t := session.GetExpiresAt(); t > newest {
+			newest = t
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "sessions:\t%d\n", total)
+	fmt.Fprintf(e.out, "live:\t%d\n", total-numExpired-invalid)
+	fmt.Fprintf(e.out, "expired:\t%d\n", numExpired)
+	fmt.Fprintf(e.out, "invalid:\t%d\n", invalid)
+	fmt.Fprintf(e.out, "bytes:\t%d\n", size)
+	if total > invalid {
+		fmt.Fprintf(e.out, "oldest expiration:\t%s\n", time.Unix(oldest, 0).Format(time.RFC3339))
+		fmt.Fprintf(e.out, "newest expiration:\t%s\n", time.Unix(newest, 0).Format(time.RFC3339))
+	}
+	return nil
+}
+
+// purgeExpired removes expired sessions and moves invalid sessions
+// to the quarantine bucket.
+func purgeExpired(e *env, args []string) error {
+	var n int
+	err := e.db.Update(func(tx *bolt.Tx) error {
+		if _, err := e.bucket(tx); err != nil {
+			return err
+		}
+		// Collect the keys first, because the bucket must not be
+		// modified while iterating over it.
+		var keys [][]byte
+		var invalidKeys, invalidData [][]byte
+		var errs []error
+		err := e.layout.ForEach(tx, func(k, v []byte) error {
+			session, err := shared.Session(v)
+			if err != nil {
+				// Copy the byte slices, because this data is
+				// not safe after the bucket is modified.
+				invalidKeys = append(invalidKeys, append([]byte(nil), k...))
+				invalidData = append(invalidData, append([]byte(nil), v...))
+				errs = append(errs, err)
+			} else if expired(session) {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := e.layout.Delete(tx, k); err != nil {
+				return err
+			}
+		}
+		if len(invalidKeys) > 0 {
+			bucket, err := tx.CreateBucketIfNotExists(e.quarantineBucketName())
+			if err != nil {
+				return err
+			}
+			now := time.Now().Unix()
+			for i, k := range invalidKeys {
+				if err := shared.Quarantine(tx, e.layout, bucket, k, invalidData[i], errs[i], now); err != nil {
+					return err
+				}
+			}
+		}
+		n = len(keys) + len(invalidKeys)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "%d sessions were removed.\n", n)
+	return nil
+}
+
+// del removes the session of the given ID.
+func del(e *env, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: boltstore delete <id>")
+	}
+	return e.db.Update(func(tx *bolt.Tx) error {
+		if _, err := e.bucket(tx); err != nil {
+			return err
+		}
+		key := e.key(args[0])
+		if e.layout.Get(tx, key) == nil {
+			return errNotFound
+		}
+		return e.layout.Delete(tx, key)
+	})
+}
+
+// export writes all sessions to the output as JSON lines.
+func export(e *env, args []string) error {
+	enc := json.NewEncoder(e.out)
+	return e.forEach(func(k, v []byte) error {
+		session, err := shared.Session(v)
+		if err != nil {
+			return fmt.Errorf("boltstore: session %s: %v", e.formatKey(k), err)
+		}
+		return enc.Encode(record{
+			ID:        e.formatKey(k),
+			ExpiresAt: session.GetExpiresAt(),
+			CreatedAt: session.GetCreatedAt(),
+			UserID:    session.GetUserID(),
+			Values:    session.Values,
+
+			ParentID:       session.GetParentID(),
+			Impersonator:   session.GetImpersonator(),
+			ImpersonatedAt: session.GetImpersonatedAt(),
+		})
+	})
+}
+
+// imp reads sessions from the input as JSON lines and puts them
+// to the database.
+func imp(e *env, args []string) error {
+	var records []record
+	scanner := bufio.NewScanner(e.in)
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return err
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	err := e.db.Update(func(tx *bolt.Tx) error {
+		if err := e.layout.Init(tx); err != nil {
+			return err
+		}
+		for _, rec := range records {
+			session := &protobuf.Session{Values: rec.Values, ExpiresAt: &rec.ExpiresAt}
+			if rec.CreatedAt != 0 {
+				session.CreatedAt = &rec.CreatedAt
+			}
+			if rec.UserID != "" {
+				session.UserID = &rec.UserID
+			}
+			if rec.ParentID != "" {
+				session.ParentID = &rec.ParentID
+				session.Impersonator = &rec.Impersonator
+				session.ImpersonatedAt = &rec.ImpersonatedAt
+			}
+			if err := e.layout.Put(tx, e.key(rec.ID), session); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "%d sessions were imported.\n", len(records))
+	return nil
+}
+
+// decodeCookie decodes the cookie value and shows its session.
+func decodeCookie(e *env, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: boltstore -keys <keys> decode-cookie <name> <value>")
+	}
+	if len(e.codecs) == 0 {
+		return errors.New("boltstore: -keys is required to decode a cookie")
+	}
+	if inline := strings.TrimPrefix(args[1], shared.InlinePrefix); inline != args[1] {
+		// The session data is carried by the cookie itself.
+		var data []byte
+		if err := securecookie.DecodeMulti(args[0], inline, &data, e.codecs...); err != nil {
+			return err
+		}
+		session, err := shared.Session(data)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(e.out, "id:\t(inline)")
+		e.printSession(session)
+		return nil
+	}
+	var id string
+	if err := securecookie.DecodeMulti(args[0], args[1], &id, e.codecs...); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "id:\t%s\n", id)
+	return e.show(id)
+}
+
+// quarantine prints the ID, the quarantining time, the size and the error
+// of all quarantined sessions.
+func quarantine(e *env, args []string) error {
+	return e.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(e.quarantineBucketName())
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			session, err := shared.ArchivedSession(v)
+			if err != nil {
+				return fmt.Errorf("boltstore: quarantined session %s: %v", e.formatKey(k), err)
+			}
+			fmt.Fprintf(e.out, "%s\t%s\t%d\t%s\n", e.formatKey(k), time.Unix(session.GetArchivedAt(), 0).Format(time.RFC3339), len(session.GetData()), session.GetReason())
+			return nil
+		})
+	})
+}
+
+// restore moves the quarantined session of the given ID back to the bucket.
+func restore(e *env, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: boltstore restore <id>")
+	}
+	return e.db.Update(func(tx *bolt.Tx) error {
+		if _, err := e.bucket(tx); err != nil {
+			return err
+		}
+		bucket := tx.Bucket(e.quarantineBucketName())
+		if bucket == nil {
+			return errNotFound
+		}
+		return shared.Restore(tx, e.layout, bucket, e.key(args[0]))
+	})
+}
+
+// compactDB compacts the database file, leaving out expired and revoked
+// sessions.
+func compactDB(e *env, args []string) error {
+	_, partitioned := e.layout.(shared.PartitionedLayout)
+	db, stats, err := compact.Swap(e.db, compact.Options{
+		BucketName:  e.bucketName,
+		Partitioned: partitioned,
+	}, nil)
+	if db != nil {
+		e.db = db
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.out, "%d sessions were copied and %d sessions were dropped.\n", stats.Copied, stats.Dropped)
+	return nil
+}
+
+// show prints the session of the given ID.
+func (e *env) show(id string) error {
+	return e.db.View(func(tx *bolt.Tx) error {
+		if _, err := e.bucket(tx); err != nil {
+			return err
+		}
+		data := e.layout.Get(tx, e.key(id))
+		if data == nil {
+			return errNotFound
+		}
+		session, err := shared.Session(data)
+		if err != nil {
+			return err
+		}
+		e.printSession(session)
+		return nil
+	})
+}
+
+// printSession prints the expiration, the status and the values
+// of the session data.
+func (e *env) printSession(session protobuf.Session) {
+	fmt.Fprintf(e.out, "expires at:\t%s\n", expiresAt(session))
+	fmt.Fprintf(e.out, "status:\t%s\n", status(session))
+	if session.ParentID != nil {
+		fmt.Fprintf(e.out, "impersonated:\t%q by %q at %s\n", session.GetUserID(), session.GetImpersonator(), time.Unix(session.GetImpersonatedAt(), 0).Format(time.RFC3339))
+	}
+	values := make(map[interface{}]interface{})
+	if err := gob.NewDecoder(bytes.NewReader(session.Values)).Decode(&values); err != nil {
+		fmt.Fprintf(e.out, "values:\t%d bytes (%v)\n", len(session.Values), err)
+		return
+	}
+	lines := make([]string, 0, len(values))
+	for k, v := range values {
+		lines = append(lines, fmt.Sprintf("\t%#v: %#v\n", k, v))
+	}
+	sort.Strings(lines)
+	fmt.Fprintln(e.out, "values:")
+	for _, line := range lines {
+		fmt.Fprint(e.out, line)
+	}
+}
+
+// key returns the database key of the session ID. If IDs are hashed,
+// the hex-encoded database key printed by formatKey is also accepted.
+func (e *env) key(id string) []byte {
+	if e.hashKey == nil {
+		return []byte(id)
+	}
+	if key, err := hex.DecodeString(id); err == nil && len(key) == sha256.Size {
+		return key
+	}
+	return shared.HashID(e.hashKey, []byte(id))
+}
+
+// formatKey returns the printable form of the database key. Hashed keys
+// are hex-encoded, so that they can be passed to key.
+func (e *env) formatKey(k []byte) string {
+	if e.hashKey == nil {
+		return string(k)
+	}
+	return hex.EncodeToString(k)
+}
+
+// quarantineBucketName returns the name of the quarantine bucket.
+func (e *env) quarantineBucketName() []byte {
+	return shared.QuarantineBucketName(e.bucketName)
+}
+
+// forEach calls the function for each session in the bucket.
+func (e *env) forEach(fn func(k, v []byte) error) error {
+	return e.db.View(func(tx *bolt.Tx) error {
+		if _, err := e.bucket(tx); err != nil {
+			return err
+		}
+		return e.layout.ForEach(tx, fn)
+	})
+}
+
+// bucket returns the bucket which contains sessions.
+func (e *env) bucket(tx *bolt.Tx) (*bolt.Bucket, error) {
+	bucket := tx.Bucket(e.bucketName)
+	if bucket == nil {
+		return nil, fmt.Errorf("boltstore: bucket %s does not exist", e.bucketName)
+	}
+	return bucket, nil
+}
+
+// expiresAt returns the formatted expiration of the session.
+func expiresAt(session protobuf.Session) string {
+	if session.ExpiresAt == nil {
+		return "-"
+	}
+	return time.Unix(*session.ExpiresAt, 0).Format(time.RFC3339)
+}
+
+// status returns the status of the session.
+func status(session protobuf.Session) string {
+	if expired(session) {
+		return "expired"
+	}
+	return "live"
+}
+
+// expired checks if the session is expired. A session without
+// its expiration is regarded as a live one.
+func expired(session protobuf.Session) bool {
+	return session.ExpiresAt != nil && shared.Expired(session)
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gorilla/securecookie"
+	"github.com/yosssi/boltstore/shared"
+	"github.com/yosssi/boltstore/store"
+)
+
+func newTestEnv(t *testing.T) *env {
+	f, err := ioutil.TempFile("", "boltstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	db, err := bolt.Open(f.Name(), 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &env{
+		db:         db,
+		bucketName: []byte(shared.DefaultBucketName),
+		layout:     layout([]byte(shared.DefaultBucketName), 0),
+		codecs:     securecookie.CodecsFromPairs([]byte("secret-key")),
+		in:         strings.NewReader(""),
+		out:        &bytes.Buffer{},
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(e.bucketName)
+		if err != nil {
+			return err
+		}
+		live, err := proto.Marshal(shared.NewSession([]byte{}, 60*60))
+		if err != nil {
+			return err
+		}
+		expired, err := proto.Marshal(shared.NewSession([]byte{}, -1))
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("live"), live); err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("expired"), expired); err != nil {
+			return err
+		}
+		return bucket.Put([]byte("invalid"), []byte("test"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func closeTestEnv(e *env) {
+	path := e.db.Path()
+	e.db.Close()
+	os.Remove(path)
+}
+
+func (e *env) output() string {
+	s := e.out.(*bytes.Buffer).String()
+	e.out.(*bytes.Buffer).Reset()
+	return s
+}
+
+func Test_list(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	if err := list(e, nil); err != nil {
+		t.Error(err)
+	}
+	out := e.output()
+	for _, s := range []string{"live\t", "expired\t", "invalid\t"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("list should print %q (actual: %s)", s, out)
+		}
+	}
+}
+
+func Test_show(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	if err := show(e, []string{"live"}); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); !strings.Contains(out, "status:\tlive") {
+		t.Errorf("show should print the status (actual: %s)", out)
+	}
+	if err := show(e, []string{"none"}); err != errNotFound {
+		t.Errorf("show should return %v (actual: %v)", errNotFound, err)
+	}
+	if err := show(e, nil); err == nil {
+		t.Error("show should return an error when no ID is given")
+	}
+}
+
+func Test_count(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	if err := count(e, nil); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); out != "3\n" {
+		t.Errorf("count should print 3 (actual: %s)", out)
+	}
+}
+
+func Test_stats(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	if err := stats(e, nil); err != nil {
+		t.Error(err)
+	}
+	out := e.output()
+	for _, s := range []string{"sessions:\t3\n", "live:\t1\n", "expired:\t1\n", "invalid:\t1\n"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("stats should print %q (actual: %s)", s, out)
+		}
+	}
+}
+
+func Test_purgeExpired(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	if err := purgeExpired(e, nil); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); out != "2 sessions were removed.\n" {
+		t.Errorf("purgeExpired should remove 2 sessions (actual: %s)", out)
+	}
+	count(e, nil)
+	if out := e.output(); out != "1\n" {
+		t.Errorf("1 session should remain (actual: %s)", out)
+	}
+	quarantine(e, nil)
+	if out := e.output(); !strings.HasPrefix(out, "invalid\t") {
+		t.Errorf("the invalid session should be quarantined (actual: %s)", out)
+	}
+}
+
+func Test_quarantine_restore(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+
+	// When the quarantine bucket does not exist
+	if err := quarantine(e, nil); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); out != "" {
+		t.Errorf("quarantine should print nothing (actual: %s)", out)
+	}
+	if err := restore(e, []string{"invalid"}); err != errNotFound {
+		t.Errorf("restore should return %v (actual: %v)", errNotFound, err)
+	}
+
+	purgeExpired(e, nil)
+	e.output()
+	if err := quarantine(e, nil); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); !strings.HasPrefix(out, "invalid\t") || !strings.Contains(out, "\t4\t") {
+		t.Errorf("quarantine should print the invalid session (actual: %s)", out)
+	}
+
+	// When the session data cannot be decoded
+	if err := restore(e, []string{"invalid"}); !errors.Is(err, shared.ErrCorruptSession) {
+		t.Errorf("restore should return an error which wraps shared.ErrCorruptSession (actual: %v)", err)
+	}
+	if err := restore(e, nil); err == nil {
+		t.Error("restore should return an error when no ID is given")
+	}
+}
+
+func Test_del(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	if err := del(e, []string{"live"}); err != nil {
+		t.Error(err)
+	}
+	if err := del(e, []string{"live"}); err != errNotFound {
+		t.Errorf("del should return %v (actual: %v)", errNotFound, err)
+	}
+}
+
+func Test_export_imp(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	if err := export(e, nil); err == nil {
+		t.Error("export should return an error when there is an invalid session")
+	}
+	e.output()
+	purgeExpired(e, nil)
+	e.output()
+	if err := export(e, nil); err != nil {
+		t.Error(err)
+	}
+	exported := e.output()
+	if !strings.Contains(exported, `"id":"live"`) {
+		t.Errorf("export should print the live session (actual: %s)", exported)
+	}
+
+	f := newTestEnv(t)
+	defer closeTestEnv(f)
+	f.bucketName = []byte("imported")
+	f.layout = layout(f.bucketName, time.Hour)
+	f.in = strings.NewReader(exported)
+	if err := imp(f, nil); err != nil {
+		t.Error(err)
+	}
+	if out := f.output(); out != "1 sessions were imported.\n" {
+		t.Errorf("imp should import 1 session (actual: %s)", out)
+	}
+	if err := show(f, []string{"live"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_show_impersonation(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	err := e.db.Update(func(tx *bolt.Tx) error {
+		session := shared.NewSession([]byte{}, 60*60)
+		userID, parentID, impersonator := "alice", "parent", "admin"
+		impersonatedAt := time.Now().Unix()
+		session.UserID = &userID
+		session.ParentID = &parentID
+		session.Impersonator = &impersonator
+		session.ImpersonatedAt = &impersonatedAt
+		return e.layout.Put(tx, []byte("child"), session)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := show(e, []string{"child"}); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); !strings.Contains(out, `impersonated:	"alice" by "admin"`) {
+		t.Errorf("show should print the impersonation (actual: %s)", out)
+	}
+	purgeExpired(e, nil)
+	e.output()
+	if err := export(e, nil); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); !strings.Contains(out, `"impersonator":"admin"`) {
+		t.Errorf("export should print the impersonation (actual: %s)", out)
+	}
+}
+
+func Test_show_namespace(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	var buf bytes.Buffer
+	values := map[interface{}]interface{}{store.NamespaceKey{Namespace: "billing", Key: "user"}: "alice"}
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		t.Fatal(err)
+	}
+	err := e.db.Update(func(tx *bolt.Tx) error {
+		return e.layout.Put(tx, []byte("namespaced"), shared.NewSession(buf.Bytes(), 60*60))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := show(e, []string{"namespaced"}); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); !strings.Contains(out, `store.NamespaceKey{Namespace:"billing", Key:"user"}: "alice"`) {
+		t.Errorf("show should print the namespaced value (actual: %s)", out)
+	}
+}
+
+func Test_decodeCookie(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	encoded, err := securecookie.EncodeMulti("test", "live", e.codecs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decodeCookie(e, []string{"test", encoded}); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); !strings.Contains(out, "id:\tlive\n") {
+		t.Errorf("decodeCookie should print the ID (actual: %s)", out)
+	}
+	if err := decodeCookie(e, []string{"other", encoded}); err == nil {
+		t.Error("decodeCookie should return an error when the name does not match")
+	}
+
+	// When the session data is carried by the cookie
+	data, err := proto.Marshal(shared.NewSession([]byte{}, 60*60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err = securecookie.EncodeMulti("test", data, e.codecs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decodeCookie(e, []string{"test", shared.InlinePrefix + encoded}); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); !strings.Contains(out, "id:\t(inline)\n") || !strings.Contains(out, "status:\tlive\n") {
+		t.Errorf("decodeCookie should print the session data (actual: %s)", out)
+	}
+}
+
+func Test_compactDB(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	db := e.db
+	if err := compactDB(e, nil); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); out != "2 sessions were copied and 1 sessions were dropped.\n" {
+		t.Errorf("compactDB should drop the expired session (actual: %s)", out)
+	}
+	if e.db == db {
+		t.Error("e.db should be replaced by the reopened database")
+	}
+	count(e, nil)
+	if out := e.output(); out != "2\n" {
+		t.Errorf("2 sessions should remain (actual: %s)", out)
+	}
+}
+
+func Test_dbOptions(t *testing.T) {
+	if o := dbOptions("list", time.Second); !o.ReadOnly || o.Timeout != time.Second {
+		t.Errorf("list should open the database read-only (actual: %+v)", o)
+	}
+	if o := dbOptions("delete", time.Second); o.ReadOnly {
+		t.Errorf("delete should open the database writable (actual: %+v)", o)
+	}
+
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	// e.db holds the lock of the file.
+	if _, err := bolt.Open(e.db.Path(), 0666, dbOptions("list", 10*time.Millisecond)); err != bolt.ErrTimeout {
+		t.Errorf("bolt.Open should return %v (actual: %v)", bolt.ErrTimeout, err)
+	}
+}
+
+func Test_keyPairs(t *testing.T) {
+	pairs := keyPairs("hash,,hash2,block2")
+	if len(pairs) != 4 || pairs[1] != nil || string(pairs[3]) != "block2" {
+		t.Errorf("keyPairs returned an invalid value (actual: %q)", pairs)
+	}
+	if keyPairs("") != nil {
+		t.Error("keyPairs should return nil when no keys are given")
+	}
+}
+
+func Test_hashKey(t *testing.T) {
+	e := newTestEnv(t)
+	defer closeTestEnv(e)
+	e.hashKey = []byte("hash-key")
+	if err := show(e, []string{"live"}); err != errNotFound {
+		t.Errorf("show should return %v (actual: %v)", errNotFound, err)
+	}
+	key := hex.EncodeToString(shared.HashID(e.hashKey, []byte("hashed")))
+	e.in = strings.NewReader(`{"id":"hashed","expiresAt":0}` + "\n")
+	if err := imp(e, nil); err != nil {
+		t.Error(err)
+	}
+	e.output()
+	if err := show(e, []string{"hashed"}); err != nil {
+		t.Error(err)
+	}
+	if err := show(e, []string{key}); err != nil {
+		t.Error(err)
+	}
+	e.output()
+	e.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(e.bucketName).Delete([]byte("invalid"))
+	})
+	if err := list(e, nil); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); !strings.Contains(out, key+"\t") {
+		t.Errorf("list should print the hex-encoded key (actual: %s)", out)
+	}
+	if err := export(e, nil); err != nil {
+		t.Error(err)
+	}
+	if out := e.output(); !strings.Contains(out, `"id":"`+key+`"`) {
+		t.Errorf("export should print the hex-encoded key (actual: %s)", out)
+	}
+	if err := del(e, []string{key}); err != nil {
+		t.Error(err)
+	}
+	if err := show(e, []string{"hashed"}); err != errNotFound {
+		t.Errorf("show should return %v (actual: %v)", errNotFound, err)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+Command boltstore operates on a Bolt database which contains sessions
+stored by BoltStore.
+
+Usage:
+
+	boltstore [flags] <command> [arguments]
+
+The commands are:
+
+	list                  list all sessions
+	show <id>             show the session of the given ID
+	count                 print the number of sessions
+	stats                 print statistics about sessions
+	purge-expired         remove expired sessions and quarantine invalid ones
+	delete <id>           remove the session of the given ID
+	export                write all sessions to the standard output as JSON lines
+	import                read sessions from the standard input as JSON lines
+	decode-cookie <name> <value>
+	                      decode the cookie value and show its session
+	quarantine            list quarantined sessions with their errors
+	restore <id>          move the quarantined session back to the bucket
+	compact               shrink the database file, dropping expired and
+	                      revoked sessions
+
+The flags are:
+
+	-db        path of the Bolt database file (default "sessions.db")
+	-bucket    name of the bucket which contains sessions (default "sessions")
+	-keys      comma-separated securecookie key pairs used by decode-cookie
+	-hash-key  key used to hash session IDs (store.Options.IDHashKey)
+	-timeout   time to wait for the lock of the database file (default 5s)
+	-partition period of the sub-buckets which contain sessions
+	           (store.Options.Partition)
+
+The database file is locked while a process writes to it, e.g. while
+the application which stores sessions is running. Commands which only read
+sessions (list, show, count, stats, export, decode-cookie and quarantine)
+open the file read-only, and all commands give up after -timeout.
+
+If -hash-key is set, the commands print the hex-encoded database keys
+instead of session IDs. Commands which take an ID accept both the session
+ID and the hex-encoded key.
+*/
+package main
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/securecookie"
+	"github.com/yosssi/boltstore/shared"
+)
+
+func main() {
+	dbPath := flag.String("db", "sessions.db", "path of the Bolt database file")
+	bucketName := flag.String("bucket", shared.DefaultBucketName, "name of the bucket which contains sessions")
+	hashKey := flag.String("hash-key", "", "key used to hash session IDs (store.Options.IDHashKey)")
+	partition := flag.Duration("partition", 0, "period of the sub-buckets which contain sessions (store.Options.Partition)")
+	keys := flag.String("keys", "", "comma-separated securecookie key pairs used by decode-cookie")
+	timeout := flag.Duration("timeout", defaultTimeout, "time to wait for the lock of the database file")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "boltstore: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	if _, err := os.Stat(*dbPath); err != nil && flag.Arg(0) != "import" {
+		fatal(err)
+	}
+
+	db, err := bolt.Open(*dbPath, 0666, dbOptions(flag.Arg(0), *timeout))
+	if err != nil {
+		if err == bolt.ErrTimeout {
+			err = fmt.Errorf("boltstore: %s is locked by another process", *dbPath)
+		}
+		fatal(err)
+	}
+
+	e := &env{
+		db:         db,
+		bucketName: []byte(*bucketName),
+		layout:     layout([]byte(*bucketName), *partition),
+		hashKey:    optionalKey(*hashKey),
+		codecs:     securecookie.CodecsFromPairs(keyPairs(*keys)...),
+		in:         os.Stdin,
+		out:        os.Stdout,
+	}
+	// Close the database of the environment, which is replaced by compact.
+	defer func() {
+		e.db.Close()
+	}()
+
+	if err := cmd(e, flag.Args()[1:]); err != nil {
+		e.db.Close()
+		fatal(err)
+	}
+}
+
+// dbOptions returns the options to open the database for the command.
+// Commands which only read sessions open the database read-only, so that
+// they share the file lock with each other.
+func dbOptions(cmd string, timeout time.Duration) *bolt.Options {
+	return &bolt.Options{Timeout: timeout, ReadOnly: readOnlyCommands[cmd]}
+}
+
+// layout returns the layout of sessions in the bucket.
+func layout(bucketName []byte, partition time.Duration) shared.Layout {
+	if partition > 0 {
+		return shared.PartitionedLayout{
+			BucketName:               bucketName,
+			PartitionIndexBucketName: shared.PartitionIndexBucketName(bucketName),
+			Partition:                partition,
+		}
+	}
+	return shared.FlatLayout{
+		BucketName:            bucketName,
+		ExpiryIndexBucketName: shared.ExpiryIndexBucketName(bucketName),
+	}
+}
+
+// keyPairs splits the comma-separated keys into securecookie key pairs.
+func keyPairs(keys string) [][]byte {
+	if keys == "" {
+		return nil
+	}
+	var pairs [][]byte
+	for _, key := range strings.Split(keys, ",") {
+		// An empty key means that no block key is used.
+		pairs = append(pairs, optionalKey(key))
+	}
+	return pairs
+}
+
+// optionalKey returns the key as a byte slice or nil if it is empty.
+func optionalKey(key string) []byte {
+	if key == "" {
+		return nil
+	}
+	return []byte(key)
+}
+
+// usage prints the usage of the command.
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: boltstore [flags] <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "commands: list, show, count, stats, purge-expired, delete, export, import, decode-cookie, quarantine, restore, compact")
+	flag.PrintDefaults()
+}
+
+// fatal prints the error and exits.
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,279 @@
+package compact
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared"
+)
+
+// Stats represents the result of a compaction.
+type Stats struct {
+	// Copied is the number of copied sessions.
+	Copied int
+	// Dropped is the number of left out expired and revoked sessions.
+	Dropped int
+}
+
+// Copy copies all buckets of src to dst, leaving out expired and revoked
+// sessions, the expiry index entries of left out sessions and expired
+// sub-buckets. Invalid session data is copied as it is. src is read in
+// a single transaction and dst is written in transactions of at most
+// options.TxMaxSize bytes.
+func Copy(dst, src *bolt.DB, options Options) (Stats, error) {
+	options.setDefault()
+	var stats Stats
+	w := &writer{db: dst, max: options.TxMaxSize}
+	err := src.View(func(tx *bolt.Tx) error {
+		f := &filter{
+			options:  options,
+			sessions: tx.Bucket(options.BucketName),
+			meta:     tx.Bucket(options.MetaBucketName),
+			now:      options.Clock.Now().Add(-options.ClockSkew).Unix(),
+			stats:    &stats,
+		}
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return w.copyBucket(nil, name, b, f)
+		})
+	})
+	if err != nil {
+		w.rollback()
+		return stats, err
+	}
+	return stats, w.commit()
+}
+
+// Swap compacts the database into a fresh file next to it, replaces
+// the database file with the fresh one and returns the reopened database.
+// The database is closed, so it must not be used during and after
+// the swap. If an error occurs before the database is closed,
+// the database itself is returned with the error. If closing it or
+// replacing the file fails, the original file is reopened and returned
+// with the error. Nil is returned only if the file cannot be reopened.
+// boltOptions are used to reopen the database.
+func Swap(db *bolt.DB, options Options, boltOptions *bolt.Options) (*bolt.DB, Stats, error) {
+	tmp, stats, err := CopyFile(db, options)
+	if err != nil {
+		return db, stats, err
+	}
+	reopened, err := Replace(db, tmp, boltOptions)
+	return reopened, stats, err
+}
+
+// CopyFile compacts the database into a fresh file next to it and returns
+// the path of the file. The database is only read, so it can be used
+// during the copy, but data written to it meanwhile are not copied.
+func CopyFile(db *bolt.DB, options Options) (string, Stats, error) {
+	path := db.Path()
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", Stats{}, err
+	}
+	tmp := path + ".compact"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return "", Stats{}, err
+	}
+
+	dst, err := bolt.Open(tmp, info.Mode(), nil)
+	if err != nil {
+		return "", Stats{}, err
+	}
+	stats, err := Copy(dst, db, options)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", stats, err
+	}
+	return tmp, stats, nil
+}
+
+// Replace closes the database, replaces its file with the compacted file
+// of the path made by CopyFile and returns the reopened database. If closing
+// it or replacing the file fails, the original file is reopened and returned
+// with the error. Nil is returned only if the file cannot be reopened.
+// boltOptions are used to reopen the database.
+func Replace(db *bolt.DB, tmp string, boltOptions *bolt.Options) (*bolt.DB, error) {
+	path := db.Path()
+	info, err := os.Stat(path)
+	if err == nil {
+		err = db.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	// Reopen the database even if closing or the rename failed,
+	// so that the original file is used instead of the closed database.
+	mode := os.FileMode(0600)
+	if info != nil {
+		mode = info.Mode()
+	}
+	reopened, oerr := bolt.Open(path, mode, boltOptions)
+	if err == nil {
+		err = oerr
+	}
+	return reopened, err
+}
+
+// filter decides which data are copied.
+type filter struct {
+	options  Options
+	sessions *bolt.Bucket
+	meta     *bolt.Bucket
+	// now is the current time less the clock skew in Unix time.
+	now   int64
+	stats *Stats
+}
+
+// keep checks if the key-value of the bucket of the path is copied.
+// v is nil if the key is a nested bucket.
+func (f *filter) keep(path [][]byte, b *bolt.Bucket, k, v []byte) bool {
+	o := f.options
+	switch {
+	case len(path) == 1 && bytes.Equal(path[0], o.BucketName) && !o.Partitioned:
+		if v == nil {
+			return true
+		}
+		if !f.live(v) {
+			f.stats.Dropped++
+			return false
+		}
+		f.stats.Copied++
+		return true
+	case len(path) == 1 && bytes.Equal(path[0], o.BucketName):
+		if v != nil || len(k) != 8 || int64(binary.BigEndian.Uint64(k)) > f.now {
+			return true
+		}
+		// Leave out the expired sub-bucket.
+		f.stats.Dropped += keyN(b.Bucket(k))
+		return false
+	case len(path) == 2 && bytes.Equal(path[0], o.BucketName) && o.Partitioned:
+		if v != nil {
+			f.stats.Copied++
+		}
+		return true
+	case len(path) == 1 && bytes.Equal(path[0], o.ExpiryIndexBucketName) && !o.Partitioned:
+		if v == nil {
+			return true
+		}
+		// Leave out the entries of the expired and the dropped sessions.
+		expiresAt, key := shared.ParseExpiryIndexKey(k)
+		if expiresAt <= f.now || f.sessions == nil {
+			return false
+		}
+		data := f.sessions.Get(key)
+		return data != nil && f.live(data)
+	case len(path) == 1 && bytes.Equal(path[0], o.PartitionIndexBucketName) && o.Partitioned:
+		return v == nil || len(v) != 8 || int64(binary.BigEndian.Uint64(v)) > f.now
+	}
+	return true
+}
+
+// live checks if the session data is neither expired nor revoked.
+// Invalid session data is regarded as a live one.
+func (f *filter) live(v []byte) bool {
+	session, err := shared.Session(v)
+	return err != nil || !((session.ExpiresAt != nil && shared.ExpiredAt(session, time.Unix(f.now, 0), 0)) || shared.Revoked(f.meta, session))
+}
+
+// writer writes data to a database in transactions of limited size.
+type writer struct {
+	db   *bolt.DB
+	tx   *bolt.Tx
+	size int
+	max  int
+}
+
+// copyBucket copies the bucket of the name under the path
+// and its nested buckets.
+func (w *writer) copyBucket(path [][]byte, name []byte, b *bolt.Bucket, f *filter) error {
+	path = append(path[:len(path):len(path)], name)
+	// Create the bucket even if it is empty.
+	bucket, err := w.bucket(path, 0)
+	if err != nil {
+		return err
+	}
+	if err := bucket.SetSequence(b.Sequence()); err != nil {
+		return err
+	}
+	return b.ForEach(func(k, v []byte) error {
+		if !f.keep(path, b, k, v) {
+			return nil
+		}
+		if v == nil {
+			return w.copyBucket(path, k, b.Bucket(k), f)
+		}
+		bucket, err := w.bucket(path, len(k)+len(v))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(k, v)
+	})
+}
+
+// bucket returns the bucket of the path in the current transaction,
+// which is committed first if writing n more bytes exceeds the limit.
+func (w *writer) bucket(path [][]byte, n int) (*bolt.Bucket, error) {
+	if w.tx != nil && w.size+n > w.max {
+		if err := w.commit(); err != nil {
+			return nil, err
+		}
+	}
+	if w.tx == nil {
+		tx, err := w.db.Begin(true)
+		if err != nil {
+			return nil, err
+		}
+		w.tx = tx
+		w.size = 0
+	}
+	w.size += n
+	bucket, err := w.tx.CreateBucketIfNotExists(path[0])
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range path[1:] {
+		if bucket, err = bucket.CreateBucketIfNotExists(name); err != nil {
+			return nil, err
+		}
+	}
+	return bucket, nil
+}
+
+// commit commits the current transaction.
+func (w *writer) commit() error {
+	if w.tx == nil {
+		return nil
+	}
+	err := w.tx.Commit()
+	w.tx = nil
+	return err
+}
+
+// rollback discards the current transaction.
+func (w *writer) rollback() {
+	if w.tx != nil {
+		w.tx.Rollback()
+		w.tx = nil
+	}
+}
+
+// keyN returns the number of keys in the bucket.
+func keyN(bucket *bolt.Bucket) int {
+	var n int
+	bucket.ForEach(func(k, v []byte) error {
+		n++
+		return nil
+	})
+	return n
+}
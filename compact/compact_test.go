@@ -0,0 +1,272 @@
+package compact
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared"
+	"github.com/yosssi/boltstore/shared/protobuf"
+)
+
+// openTestDB opens a temporary database.
+func openTestDB(t *testing.T) *bolt.DB {
+	f, err := ioutil.TempFile("", "boltstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	db, err := bolt.Open(f.Name(), 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// removeTestDB closes and removes the temporary database.
+func removeTestDB(db *bolt.DB) {
+	path := db.Path()
+	db.Close()
+	os.Remove(path)
+}
+
+// putSessions puts live, expired, revoked and invalid sessions
+// through the layout.
+func putSessions(tx *bolt.Tx, l shared.Layout, options Options) error {
+	if err := l.Init(tx); err != nil {
+		return err
+	}
+	meta, err := tx.CreateBucketIfNotExists(options.MetaBucketName)
+	if err != nil {
+		return err
+	}
+	if err := shared.SetRevocationEpoch(meta, "revoked", time.Now().Unix()+1); err != nil {
+		return err
+	}
+	userID := "revoked"
+	revoked := shared.NewSession([]byte{}, 60*60)
+	revoked.UserID = &userID
+	for key, session := range map[string]*protobuf.Session{
+		"live":    shared.NewSession([]byte{}, 60*60),
+		"expired": shared.NewSession([]byte{}, -2*60),
+		"revoked": revoked,
+	} {
+		if err := l.Put(tx, []byte(key), session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keys returns the keys of the bucket of the path.
+func keys(tx *bolt.Tx, path ...string) []string {
+	bucket := tx.Bucket([]byte(path[0]))
+	for _, name := range path[1:] {
+		if bucket == nil {
+			return nil
+		}
+		bucket = bucket.Bucket([]byte(name))
+	}
+	if bucket == nil {
+		return nil
+	}
+	var keys []string
+	bucket.ForEach(func(k, v []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	})
+	return keys
+}
+
+func TestOptions_setDefault(t *testing.T) {
+	options := Options{}
+	options.setDefault()
+	if string(options.BucketName) != shared.DefaultBucketName {
+		t.Errorf("options.BucketName should be %s (actual: %s)", shared.DefaultBucketName, options.BucketName)
+	}
+	if string(options.MetaBucketName) != shared.DefaultBucketName+shared.MetaBucketSuffix {
+		t.Errorf("options.MetaBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.MetaBucketSuffix, options.MetaBucketName)
+	}
+	if string(options.ExpiryIndexBucketName) != shared.DefaultBucketName+shared.ExpiryIndexBucketSuffix {
+		t.Errorf("options.ExpiryIndexBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.ExpiryIndexBucketSuffix, options.ExpiryIndexBucketName)
+	}
+	if string(options.PartitionIndexBucketName) != shared.DefaultBucketName+shared.PartitionIndexBucketSuffix {
+		t.Errorf("options.PartitionIndexBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.PartitionIndexBucketSuffix, options.PartitionIndexBucketName)
+	}
+	if options.TxMaxSize != shared.DefaultTxMaxSize {
+		t.Errorf("options.TxMaxSize should be %d (actual: %d)", shared.DefaultTxMaxSize, options.TxMaxSize)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	src := openTestDB(t)
+	defer removeTestDB(src)
+	dst := openTestDB(t)
+	defer removeTestDB(dst)
+
+	// Use a tiny limit so that many transactions are committed.
+	options := Options{TxMaxSize: 16}
+	options.setDefault()
+	l := shared.FlatLayout{BucketName: options.BucketName, ExpiryIndexBucketName: options.ExpiryIndexBucketName}
+	err := src.Update(func(tx *bolt.Tx) error {
+		if err := putSessions(tx, l, options); err != nil {
+			return err
+		}
+		if err := tx.Bucket(options.BucketName).Put([]byte("invalid"), []byte("test")); err != nil {
+			return err
+		}
+		// Add another bucket which has a nested bucket and a sequence.
+		other, err := tx.CreateBucket([]byte("other"))
+		if err != nil {
+			return err
+		}
+		if _, err := other.NextSequence(); err != nil {
+			return err
+		}
+		if _, err := other.CreateBucket([]byte("empty")); err != nil {
+			return err
+		}
+		for i := 0; i < 10; i++ {
+			if err := other.Put([]byte(fmt.Sprintf("key%d", i)), []byte("value")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := Copy(dst, src, options)
+	if err != nil {
+		t.Error(err)
+	}
+	if stats.Copied != 2 || stats.Dropped != 2 {
+		t.Errorf("Copy should copy 2 sessions and drop 2 sessions (actual: %+v)", stats)
+	}
+
+	err = dst.View(func(tx *bolt.Tx) error {
+		if actual := keys(tx, string(options.BucketName)); fmt.Sprint(actual) != "[invalid live]" {
+			t.Errorf("the live and invalid sessions should be copied (actual: %v)", actual)
+		}
+		if actual := keys(tx, string(options.ExpiryIndexBucketName)); len(actual) != 1 {
+			t.Errorf("only the index entry of the live session should be copied (actual: %v)", actual)
+		}
+		if actual := keys(tx, string(options.MetaBucketName), "user_epochs"); len(actual) != 1 {
+			t.Errorf("the revocation epoch should be copied (actual: %v)", actual)
+		}
+		other := tx.Bucket([]byte("other"))
+		if other == nil || other.Sequence() != 1 || other.Bucket([]byte("empty")) == nil || len(keys(tx, "other")) != 11 {
+			t.Error("the other bucket should be copied")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCopy_partitioned(t *testing.T) {
+	src := openTestDB(t)
+	defer removeTestDB(src)
+	dst := openTestDB(t)
+	defer removeTestDB(dst)
+
+	options := Options{Partitioned: true}
+	options.setDefault()
+	l := shared.PartitionedLayout{BucketName: options.BucketName, PartitionIndexBucketName: options.PartitionIndexBucketName, Partition: time.Minute}
+	if err := src.Update(func(tx *bolt.Tx) error { return putSessions(tx, l, options) }); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := Copy(dst, src, options)
+	if err != nil {
+		t.Error(err)
+	}
+	if stats.Copied != 2 || stats.Dropped != 1 {
+		t.Errorf("Copy should copy 2 sessions and drop 1 session (actual: %+v)", stats)
+	}
+
+	err = dst.View(func(tx *bolt.Tx) error {
+		if l.Get(tx, []byte("live")) == nil || l.Get(tx, []byte("revoked")) == nil {
+			t.Error("the sessions in the live partition should be copied")
+		}
+		if actual := keys(tx, string(options.BucketName)); len(actual) != 1 {
+			t.Errorf("only the live partition should be copied (actual: %d partitions)", len(actual))
+		}
+		if actual := keys(tx, string(options.PartitionIndexBucketName)); fmt.Sprint(actual) != "[live revoked]" {
+			t.Errorf("the index entries of the live partition should be copied (actual: %v)", actual)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	db := openTestDB(t)
+	path := db.Path()
+	defer os.Remove(path)
+
+	options := Options{}
+	options.setDefault()
+	l := shared.FlatLayout{BucketName: options.BucketName, ExpiryIndexBucketName: options.ExpiryIndexBucketName}
+	err := db.Update(func(tx *bolt.Tx) error {
+		if err := l.Init(tx); err != nil {
+			return err
+		}
+		for i := 0; i < 5000; i++ {
+			if err := l.Put(tx, []byte(fmt.Sprintf("expired%d", i)), shared.NewSession(make([]byte, 512), -1)); err != nil {
+				return err
+			}
+		}
+		return l.Put(tx, []byte("live"), shared.NewSession([]byte{}, 60*60))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, stats, err := Swap(db, options, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if reopened == nil || reopened == db {
+		t.Fatal("Swap should return the reopened database")
+	}
+	defer reopened.Close()
+	if stats.Copied != 1 || stats.Dropped != 5000 {
+		t.Errorf("Swap should copy 1 session and drop 5000 sessions (actual: %+v)", stats)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Errorf("the file should shrink (actual: %d -> %d)", before.Size(), after.Size())
+	}
+	if _, err := os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Error("the temporary file should be removed")
+	}
+	err = reopened.View(func(tx *bolt.Tx) error {
+		if l.Get(tx, []byte("live")) == nil {
+			t.Error("the live session should remain")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// When the database is closed
+	if _, _, err := Swap(db, options, nil); err == nil {
+		t.Error("Swap should return an error")
+	}
+}
@@ -0,0 +1,6 @@
+/*
+Package compact provides compaction of a Bolt database which contains
+sessions. Bolt never shrinks its file, so the live sessions are copied
+to a fresh file which replaces the original one.
+*/
+package compact
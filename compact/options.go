@@ -0,0 +1,61 @@
+package compact
+
+import (
+	"time"
+
+	"github.com/yosssi/boltstore/shared"
+)
+
+// Options represents options for compaction.
+type Options struct {
+	// BucketName represents the name of the bucket which contains sessions.
+	BucketName []byte
+	// MetaBucketName represents the name of the bucket which contains
+	// the revocation epochs. The name of the bucket which contains sessions
+	// suffixed with shared.MetaBucketSuffix is used if it is nil.
+	MetaBucketName []byte
+	// ExpiryIndexBucketName represents the name of the bucket which indexes
+	// sessions by their expiration. The name of the bucket which contains
+	// sessions suffixed with shared.ExpiryIndexBucketSuffix is used if it
+	// is nil.
+	ExpiryIndexBucketName []byte
+	// Partitioned must be set if the store keeps sessions in sub-buckets
+	// (store.Options.Partition).
+	Partitioned bool
+	// PartitionIndexBucketName represents the name of the bucket which maps
+	// sessions to their sub-buckets. The name of the bucket which contains
+	// sessions suffixed with shared.PartitionIndexBucketSuffix is used if
+	// it is nil.
+	PartitionIndexBucketName []byte
+	// TxMaxSize represents the maximum number of bytes written
+	// in a transaction of the destination database.
+	TxMaxSize int
+	// Clock represents the clock which decides the expiration of sessions.
+	// shared.SystemClock is used if it is nil.
+	Clock shared.Clock
+	// ClockSkew represents the grace period after the expiration during
+	// which sessions are still copied.
+	ClockSkew time.Duration
+}
+
+// setDefault sets default to the compaction options.
+func (o *Options) setDefault() {
+	if o.BucketName == nil {
+		o.BucketName = []byte(shared.DefaultBucketName)
+	}
+	if o.MetaBucketName == nil {
+		o.MetaBucketName = shared.MetaBucketName(o.BucketName)
+	}
+	if o.ExpiryIndexBucketName == nil {
+		o.ExpiryIndexBucketName = shared.ExpiryIndexBucketName(o.BucketName)
+	}
+	if o.PartitionIndexBucketName == nil {
+		o.PartitionIndexBucketName = shared.PartitionIndexBucketName(o.BucketName)
+	}
+	if o.TxMaxSize == 0 {
+		o.TxMaxSize = shared.DefaultTxMaxSize
+	}
+	if o.Clock == nil {
+		o.Clock = shared.SystemClock{}
+	}
+}
@@ -0,0 +1,55 @@
+package reaper
+
+import (
+	"log"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/compact"
+)
+
+// Compactor represents something which compacts a database file
+// and owns the reopened database. store.Store implements it.
+type Compactor interface {
+	// Compact compacts the database file and returns the reopened database
+	// with the stats.
+	Compact() (*bolt.DB, compact.Stats, error)
+	// DB returns the database which the compactor uses.
+	DB() *bolt.DB
+}
+
+// compact compacts the database of the target through its compactor
+// if it is the quiet hours and the last compaction is old enough.
+// Targets which use the database get it from the compactor, so they use
+// the reopened one after that.
+func (r *Reaper) compact(t *target, now time.Time) {
+	o := t.options
+	if o.Compactor == nil || !quietHours(now, o.CompactFrom, o.CompactUntil) || now.Sub(t.compactedAt) < o.CompactInterval {
+		return
+	}
+	t.compactedAt = now
+
+	_, _, err := o.Compactor.Compact()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		log.Printf("boltstore: compact database error: %v", err)
+		t.stats.LastError = err
+		return
+	}
+	t.stats.Compactions++
+}
+
+// quietHours checks if the time of day of now is between from and until.
+func quietHours(now time.Time, from, until time.Duration) bool {
+	if from == until {
+		return true
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	d := now.Sub(midnight)
+	if from < until {
+		return from <= d && d < until
+	}
+	return from <= d || d < until
+}
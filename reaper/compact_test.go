@@ -0,0 +1,115 @@
+package reaper
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/compact"
+)
+
+// testCompactor switches to the compacted database unless it returns
+// the error.
+type testCompactor struct {
+	db        *bolt.DB
+	compacted *bolt.DB
+	err       error
+	n         int
+}
+
+func (c *testCompactor) Compact() (*bolt.DB, compact.Stats, error) {
+	c.n++
+	if c.err != nil {
+		return c.db, compact.Stats{}, c.err
+	}
+	c.db = c.compacted
+	return c.db, compact.Stats{}, nil
+}
+
+func (c *testCompactor) DB() *bolt.DB {
+	return c.db
+}
+
+func TestReaper_compact(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	f, err := ioutil.TempFile("", "boltstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	compacted, err := bolt.Open(f.Name(), 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer compacted.Close()
+
+	c := &testCompactor{db: db, compacted: compacted}
+	r := NewMulti(
+		Target{DB: db, Options: Options{Compactor: c}},
+		Target{DB: db, Options: Options{BucketName: []byte("other")}},
+		Target{DB: compacted, Options: Options{BucketName: []byte("other")}},
+	)
+	now := time.Now()
+
+	r.compact(r.targets[0], now)
+	if c.n != 1 || r.targets[0].database() != compacted || r.targets[1].database() != compacted {
+		t.Error("all targets should use the compacted database")
+	}
+	if r.targets[2].owner != nil {
+		t.Error("the target of the other database should not be owned by the compactor")
+	}
+	if stats := r.Stats(); stats.Compactions != 1 {
+		t.Errorf("r.Stats().Compactions should be %d (actual: %d)", 1, stats.Compactions)
+	}
+
+	// When the last compaction is not old enough
+	r.compact(r.targets[0], now.Add(time.Hour))
+	if c.n != 1 {
+		t.Error("the database should not be compacted")
+	}
+
+	// When the compactor returns an error
+	c.err = errors.New("test")
+	r.compact(r.targets[0], now.Add(25*time.Hour))
+	if stats := r.Stats(); c.n != 2 || stats.Compactions != 1 || stats.LastError != c.err {
+		t.Errorf("the error should be recorded (actual: %+v)", stats)
+	}
+
+	// When the target has no compactor
+	r.compact(r.targets[1], now)
+	if c.n != 2 {
+		t.Error("the database should not be compacted")
+	}
+}
+
+func Test_quietHours(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2015, 1, 1, hour, 30, 0, 0, time.Local)
+	}
+	cases := []struct {
+		now         time.Time
+		from, until time.Duration
+		expected    bool
+	}{
+		{at(12), 0, 0, true},
+		{at(3), 2 * time.Hour, 5 * time.Hour, true},
+		{at(6), 2 * time.Hour, 5 * time.Hour, false},
+		{at(23), 22 * time.Hour, 4 * time.Hour, true},
+		{at(1), 22 * time.Hour, 4 * time.Hour, true},
+		{at(12), 22 * time.Hour, 4 * time.Hour, false},
+	}
+	for _, c := range cases {
+		if actual := quietHours(c.now, c.from, c.until); actual != c.expected {
+			t.Errorf("quietHours(%v, %v, %v) should return %t (actual: %t)", c.now, c.from, c.until, c.expected, actual)
+		}
+	}
+}
@@ -3,18 +3,115 @@ package reaper
 import (
 	"time"
 
+	"github.com/boltdb/bolt"
 	"github.com/yosssi/boltstore/shared"
+	"github.com/yosssi/boltstore/shared/protobuf"
 )
 
 // Options represents options for the reaper.
 type Options struct {
 	// BucketName represents the name of the bucket which contains sessions.
 	BucketName []byte
+	// MetaBucketName represents the name of the bucket which contains
+	// the revocation epochs. The name of the bucket which contains sessions
+	// suffixed with shared.MetaBucketSuffix is used if it is nil.
+	MetaBucketName []byte
+	// ExpiryIndexBucketName represents the name of the bucket which indexes
+	// sessions by their expiration. The name of the bucket which contains
+	// sessions suffixed with shared.ExpiryIndexBucketSuffix is used if it
+	// is nil.
+	ExpiryIndexBucketName []byte
+	// UseExpiryIndex makes the reaper seek only the expired entries of
+	// the expiry index instead of walking the entire bucket. Revoked
+	// sessions are not found through the index; they are removed when
+	// they are loaded or when they expire.
+	UseExpiryIndex bool
+	// Partitioned makes the reaper drop expired sub-buckets as a whole.
+	// It must be set if the store keeps sessions in sub-buckets
+	// (store.Options.Partition). BatchSize limits the number of sub-buckets
+	// dropped at one time. Revoked sessions are not removed before their
+	// sub-buckets expire.
+	Partitioned bool
+	// PartitionIndexBucketName represents the name of the bucket which maps
+	// sessions to their sub-buckets. The name of the bucket which contains
+	// sessions suffixed with shared.PartitionIndexBucketSuffix is used if
+	// it is nil.
+	PartitionIndexBucketName []byte
+	// QuarantineBucketName represents the name of the bucket to which
+	// sessions with invalid session data are moved with their errors.
+	// The name of the bucket which contains sessions suffixed with
+	// shared.QuarantineBucketSuffix is used if it is nil.
+	QuarantineBucketName []byte
+	// RememberTokens makes the reaper remove expired and revoked
+	// remember-me tokens (store.Remember) too. They are checked as
+	// another target of the reaper.
+	RememberTokens bool
+	// RememberBucketName represents the name of the bucket which contains
+	// remember-me tokens. The name of the bucket which contains sessions
+	// suffixed with shared.RememberBucketSuffix is used if it is nil.
+	RememberBucketName []byte
+	// Archive makes the reaper move expired and revoked sessions
+	// to the archive bucket with the reason and the time instead of
+	// removing them.
+	Archive bool
+	// ArchiveBucketName represents the name of the archive bucket.
+	// The name of the bucket which contains sessions suffixed with
+	// shared.ArchiveBucketSuffix is used if it is nil.
+	ArchiveBucketName []byte
+	// ArchiveDB represents the database which contains the archive bucket.
+	// The database which contains sessions is used if it is nil. Sessions
+	// are archived before they are removed, so a session may be archived
+	// twice if removing it fails.
+	ArchiveDB *bolt.DB
+	// Retention represents how long archived sessions are retained.
+	// The reaper purges older ones in batches of BatchSize. Archived
+	// sessions are retained forever if it is zero.
+	Retention time.Duration
+	// OnExpire is called for each expired or revoked session inside
+	// the transaction which removes it, so that data related to the session
+	// in other buckets can be removed atomically with it. id is the database
+	// key of the session, which is the hashed ID if IDs are hashed. If it
+	// returns an error, the transaction is rolled back and the sessions are
+	// checked again next time. It is not called for invalid session data.
+	OnExpire func(tx *bolt.Tx, id []byte, session protobuf.Session) error
+	// Compactor compacts the database file during the quiet hours between
+	// CompactFrom and CompactUntil. A store.Store can be used as it. The
+	// reaper uses the reopened database after that.
+	Compactor Compactor
+	// CompactFrom and CompactUntil represent the quiet hours as offsets
+	// from midnight in local time (e.g. 2*time.Hour and 5*time.Hour).
+	// The hours wrap around midnight if CompactFrom is after CompactUntil.
+	// The database is compacted at any time if both are equal.
+	CompactFrom  time.Duration
+	CompactUntil time.Duration
+	// CompactInterval represents the minimum interval between compactions.
+	CompactInterval time.Duration
+	// Clock represents the clock which decides the expiration of sessions
+	// and the invocation of the reaper. Its timers are used if it is
+	// a shared.TimerClock. shared.SystemClock is used if it is nil.
+	Clock shared.Clock
+	// ClockSkew represents the grace period after the expiration during
+	// which sessions are not removed. It should match
+	// store.Config.ClockSkew.
+	ClockSkew time.Duration
 	// BatchSize represents the maximum number of sessions which the reaper
 	// process at one time.
 	BatchSize int
 	// CheckInterval represents the interval between the reaper's invocation.
 	CheckInterval time.Duration
+	// Adaptive makes the reaper shorten the interval while batches are
+	// full of expired sessions, lengthen it while there is nothing to
+	// remove and back off on errors, within MinCheckInterval and
+	// MaxCheckInterval.
+	Adaptive bool
+	// MinCheckInterval represents the minimum interval in adaptive mode.
+	MinCheckInterval time.Duration
+	// MaxCheckInterval represents the maximum interval in adaptive mode.
+	MaxCheckInterval time.Duration
+	// Jitter represents the fraction of the interval by which each
+	// interval is randomly lengthened or shortened in adaptive mode.
+	// A negative value disables the jitter.
+	Jitter float64
 }
 
 // setDefault sets default to the reaper options.
@@ -22,10 +119,43 @@ func (o *Options) setDefault() {
 	if o.BucketName == nil {
 		o.BucketName = []byte(shared.DefaultBucketName)
 	}
+	if o.MetaBucketName == nil {
+		o.MetaBucketName = shared.MetaBucketName(o.BucketName)
+	}
+	if o.ExpiryIndexBucketName == nil {
+		o.ExpiryIndexBucketName = shared.ExpiryIndexBucketName(o.BucketName)
+	}
+	if o.PartitionIndexBucketName == nil {
+		o.PartitionIndexBucketName = shared.PartitionIndexBucketName(o.BucketName)
+	}
+	if o.QuarantineBucketName == nil {
+		o.QuarantineBucketName = shared.QuarantineBucketName(o.BucketName)
+	}
+	if o.RememberBucketName == nil {
+		o.RememberBucketName = shared.RememberBucketName(o.BucketName)
+	}
+	if o.ArchiveBucketName == nil {
+		o.ArchiveBucketName = shared.ArchiveBucketName(o.BucketName)
+	}
+	if o.Clock == nil {
+		o.Clock = shared.SystemClock{}
+	}
+	if o.CompactInterval == 0 {
+		o.CompactInterval = shared.DefaultCompactInterval
+	}
 	if o.BatchSize == 0 {
 		o.BatchSize = shared.DefaultBatchSize
 	}
 	if o.CheckInterval == 0 {
 		o.CheckInterval = shared.DefaultCheckInterval
 	}
+	if o.MinCheckInterval == 0 {
+		o.MinCheckInterval = shared.DefaultMinCheckInterval
+	}
+	if o.MaxCheckInterval == 0 {
+		o.MaxCheckInterval = shared.DefaultMaxCheckInterval
+	}
+	if o.Jitter == 0 {
+		o.Jitter = shared.DefaultJitter
+	}
 }
@@ -12,10 +12,37 @@ func TestOptions_setDefault(t *testing.T) {
 	if string(options.BucketName) != shared.DefaultBucketName {
 		t.Errorf("options.BucketName should be %+v (actual: %+v)", []byte(shared.DefaultBucketName), options.BucketName)
 	}
+	if string(options.MetaBucketName) != shared.DefaultBucketName+shared.MetaBucketSuffix {
+		t.Errorf("options.MetaBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.MetaBucketSuffix, options.MetaBucketName)
+	}
+	if string(options.ExpiryIndexBucketName) != shared.DefaultBucketName+shared.ExpiryIndexBucketSuffix {
+		t.Errorf("options.ExpiryIndexBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.ExpiryIndexBucketSuffix, options.ExpiryIndexBucketName)
+	}
+	if string(options.PartitionIndexBucketName) != shared.DefaultBucketName+shared.PartitionIndexBucketSuffix {
+		t.Errorf("options.PartitionIndexBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.PartitionIndexBucketSuffix, options.PartitionIndexBucketName)
+	}
+	if string(options.QuarantineBucketName) != shared.DefaultBucketName+shared.QuarantineBucketSuffix {
+		t.Errorf("options.QuarantineBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.QuarantineBucketSuffix, options.QuarantineBucketName)
+	}
+	if string(options.ArchiveBucketName) != shared.DefaultBucketName+shared.ArchiveBucketSuffix {
+		t.Errorf("options.ArchiveBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.ArchiveBucketSuffix, options.ArchiveBucketName)
+	}
+	if options.CompactInterval != shared.DefaultCompactInterval {
+		t.Errorf("options.CompactInterval should be %+v (actual: %+v)", shared.DefaultCompactInterval, options.CompactInterval)
+	}
 	if options.BatchSize != shared.DefaultBatchSize {
 		t.Errorf("options.BucketName should be %+d (actual: %+d)", shared.DefaultBatchSize, options.BatchSize)
 	}
 	if options.CheckInterval != shared.DefaultCheckInterval {
 		t.Errorf("options.BucketName should be %+v (actual: %+v)", shared.DefaultCheckInterval, options.CheckInterval)
 	}
+	if options.MinCheckInterval != shared.DefaultMinCheckInterval {
+		t.Errorf("options.MinCheckInterval should be %+v (actual: %+v)", shared.DefaultMinCheckInterval, options.MinCheckInterval)
+	}
+	if options.MaxCheckInterval != shared.DefaultMaxCheckInterval {
+		t.Errorf("options.MaxCheckInterval should be %+v (actual: %+v)", shared.DefaultMaxCheckInterval, options.MaxCheckInterval)
+	}
+	if options.Jitter != shared.DefaultJitter {
+		t.Errorf("options.Jitter should be %+v (actual: %+v)", shared.DefaultJitter, options.Jitter)
+	}
 }
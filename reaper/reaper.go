@@ -1,7 +1,9 @@
 package reaper
 
 import (
+	"context"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -12,15 +14,205 @@ import (
 //### Public ###//
 //##############//
 
+// Stats represents statistics of a reaper.
+type Stats struct {
+	// Scanned is the number of checked sessions.
+	Scanned uint64
+	// Deleted is the number of removed sessions.
+	Deleted uint64
+	// Corrupt is the number of found sessions with invalid session data.
+	Corrupt uint64
+	// Archived is the number of sessions moved to the archive bucket.
+	Archived uint64
+	// Purged is the number of archived sessions removed after
+	// the retention period.
+	Purged uint64
+	// Compactions is the number of compactions of the database.
+	Compactions uint64
+	// LastRun is the time when the reaper ran last.
+	LastRun time.Time
+	// LastError is the error of the last failed run.
+	LastError error
+}
+
+// Target represents a bucket in a database which a reaper checks.
+// The batch size and the interval of each target are taken from
+// its options.
+type Target struct {
+	DB      *bolt.DB
+	Options Options
+}
+
+// Reaper checks and removes expired sessions periodically.
+type Reaper struct {
+	targets []*target
+	// turn is the index of the target which runs first next time,
+	// so that every target has a chance to run first.
+	turn int
+
+	runNowC chan struct{}
+	stopC   chan struct{}
+	doneC   chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+}
+
+// New creates and returns a reaper which checks a bucket in the database.
+func New(db *bolt.DB, options Options) *Reaper {
+	return NewMulti(Target{DB: db, Options: options})
+}
+
+// NewMulti creates and returns a reaper which checks all the targets.
+// Targets due at the same time run one batch each in turn.
+func NewMulti(targets ...Target) *Reaper {
+	r := &Reaper{
+		runNowC: make(chan struct{}, 1),
+		stopC:   make(chan struct{}),
+		doneC:   make(chan struct{}),
+	}
+	for _, t := range targets {
+		t.Options.setDefault()
+		r.targets = append(r.targets, newTarget(t, t.Options.Clock.Now()))
+		if t.Options.RememberTokens {
+			r.targets = append(r.targets, newTarget(rememberTarget(t), t.Options.Clock.Now()))
+		}
+	}
+	// The compactor of a database owns it, because it is replaced
+	// by compaction.
+	for _, t := range r.targets {
+		if t.options.Compactor == nil {
+			continue
+		}
+		for _, u := range r.targets {
+			if u.db == t.db {
+				u.owner = t.options.Compactor
+			}
+		}
+	}
+	return r
+}
+
+// rememberTarget returns the target which checks the remember-me tokens
+// of the target. Tokens are revoked with the sessions of their users.
+func rememberTarget(t Target) Target {
+	o := t.Options
+	return Target{
+		DB: t.DB,
+		Options: Options{
+			BucketName:       o.RememberBucketName,
+			MetaBucketName:   o.MetaBucketName,
+			Clock:            o.Clock,
+			ClockSkew:        o.ClockSkew,
+			BatchSize:        o.BatchSize,
+			CheckInterval:    o.CheckInterval,
+			Adaptive:         o.Adaptive,
+			MinCheckInterval: o.MinCheckInterval,
+			MaxCheckInterval: o.MaxCheckInterval,
+			Jitter:           o.Jitter,
+		},
+	}
+}
+
+// Start invokes the reaper as a goroutine. The reaper runs until
+// the context is done or Stop is called. A reaper can be started only once.
+func (r *Reaper) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return
+	}
+	r.started = true
+	go r.loop(ctx)
+}
+
+// Stop terminates the reaper and waits for it to finish. The context's
+// error is returned if the context is done before the reaper finishes.
+func (r *Reaper) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.started {
+		r.mu.Unlock()
+		return nil
+	}
+	if !r.stopped {
+		r.stopped = true
+		close(r.stopC)
+	}
+	r.mu.Unlock()
+	select {
+	case <-r.doneC:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RunNow makes the running reaper check all sessions of all targets
+// immediately.
+func (r *Reaper) RunNow() {
+	select {
+	case r.runNowC <- struct{}{}:
+	default:
+		// A sweep is already requested.
+	}
+}
+
+// Stats returns the statistics of the reaper summed over all targets.
+// LastRun is the latest one among the targets and LastError is the first
+// one found in the order of the targets.
+func (r *Reaper) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var stats Stats
+	for _, t := range r.targets {
+		stats.Scanned += t.stats.Scanned
+		stats.Deleted += t.stats.Deleted
+		stats.Corrupt += t.stats.Corrupt
+		stats.Archived += t.stats.Archived
+		stats.Purged += t.stats.Purged
+		stats.Compactions += t.stats.Compactions
+		if t.stats.LastRun.After(stats.LastRun) {
+			stats.LastRun = t.stats.LastRun
+		}
+		if stats.LastError == nil {
+			stats.LastError = t.stats.LastError
+		}
+	}
+	return stats
+}
+
+// TargetStats returns the statistics of each target in the order
+// in which the targets were given. The remember-me tokens of a target
+// (Options.RememberTokens) follow it as another target.
+func (r *Reaper) TargetStats() []Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := make([]Stats, len(r.targets))
+	for i, t := range r.targets {
+		stats[i] = t.stats
+	}
+	return stats
+}
+
 // Run invokes a reap function as a goroutine.
+//
+// Deprecated: Use New and Reaper.Start instead.
 func Run(db *bolt.DB, options Options) (chan<- struct{}, <-chan struct{}) {
-	options.setDefault()
+	r := New(db, options)
+	r.Start(context.Background())
 	quitC, doneC := make(chan struct{}), make(chan struct{})
-	go reap(db, options, quitC, doneC)
+	go func() {
+		<-quitC
+		r.Stop(context.Background())
+		doneC <- struct{}{}
+	}()
 	return quitC, doneC
 }
 
 // Quit terminates the reap goroutine.
+//
+// Deprecated: Use Reaper.Stop instead.
 func Quit(quitC chan<- struct{}, doneC <-chan struct{}) {
 	quitC <- struct{}{}
 	<-doneC
@@ -30,110 +222,558 @@ func Quit(quitC chan<- struct{}, doneC <-chan struct{}) {
 //### Private ###//
 //###############//
 
-func reap(db *bolt.DB, options Options, quitC <-chan struct{}, doneC chan<- struct{}) {
-	// Create a new ticker
-	ticker := time.NewTicker(options.CheckInterval)
+// batch represents the result of a reaper's invocation.
+type batch struct {
+	// scanned is the number of checked sessions.
+	scanned int
+	// deleted is the number of removed sessions.
+	deleted int
+	// corrupt is the number of found sessions with invalid session data.
+	corrupt int
+	// archived is the number of sessions moved to the archive bucket.
+	archived int
+	// purged is the number of archived sessions removed after
+	// the retention period.
+	purged int
+	// full is true if the batch was filled with expired sessions,
+	// which means that more expired sessions may remain.
+	full bool
+	// done is true if the batch reached the end of the sessions.
+	done bool
+}
+
+// target represents a target and the state of its invocations.
+type target struct {
+	db *bolt.DB
+	// owner is the compactor of the database, which is used
+	// instead of db if it is set.
+	owner    Compactor
+	options  Options
+	prevKey  []byte
+	schedule *schedule
+	// next is the time when the target runs next time.
+	next time.Time
+	// compactedAt is the time when the database was compacted last.
+	compactedAt time.Time
+	stats       Stats
+}
+
+// database returns the database of the target.
+func (t *target) database() *bolt.DB {
+	if t.owner != nil {
+		return t.owner.DB()
+	}
+	return t.db
+}
+
+// loop invokes the reaper periodically until it is stopped.
+func (r *Reaper) loop(ctx context.Context) {
+	defer close(r.doneC)
+
+	// Create a new timer
+	timer := shared.NewTimer(r.clock(), r.wait(r.now()))
 
 	defer func() {
-		// Stop the ticker
-		ticker.Stop()
+		// Stop the timer
+		timer.Stop()
 	}()
 
-	var prevKey []byte
-
 	for {
 		select {
-		case <-quitC: // Check if a quit signal is sent.
-			doneC <- struct{}{}
+		case <-ctx.Done(): // Check if the context is done.
 			return
-		case <-ticker.C: // Check if the ticker fires a signal.
-			// This slice is a buffer to save all expired session keys.
-			expiredSessionKeys := make([][]byte, 0)
-
-			// Start a bolt read transaction.
-			err := db.View(func(tx *bolt.Tx) error {
-				bucket := tx.Bucket(options.BucketName)
-				if bucket == nil {
-					return nil
-				}
+		case <-r.stopC: // Check if the reaper is stopped.
+			return
+		case <-r.runNowC: // Check if a sweep is requested.
+			r.sweep(ctx)
+		case <-timer.C(): // Check if the timer fires a signal.
+			r.runDue(r.now())
+			timer.Reset(r.wait(r.now()))
+		}
+	}
+}
 
-				c := bucket.Cursor()
+// clock returns the clock which schedules the reaper. It is the clock
+// of the first target.
+func (r *Reaper) clock() shared.Clock {
+	if len(r.targets) == 0 {
+		return shared.SystemClock{}
+	}
+	return r.targets[0].options.Clock
+}
 
-				var i int
-				var isExpired bool
+// now returns the current time of the clock of the reaper.
+func (r *Reaper) now() time.Time {
+	return r.clock().Now()
+}
 
-				for k, v := c.Seek(prevKey); ; k, v = c.Next() {
-					// If we hit the end of our sessions then
-					// exit and start over next time.
-					if k == nil {
-						prevKey = nil
-						return nil
-					}
+// wait returns the duration until the earliest target is due.
+func (r *Reaper) wait(now time.Time) time.Duration {
+	if len(r.targets) == 0 {
+		return shared.DefaultCheckInterval
+	}
+	next := r.targets[0].next
+	for _, t := range r.targets[1:] {
+		if t.next.Before(next) {
+			next = t.next
+		}
+	}
+	if d := next.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
 
-					i++
+// runDue invokes a batch of each target which is due at now
+// and schedules its next invocation.
+func (r *Reaper) runDue(now time.Time) {
+	n := len(r.targets)
+	for i := 0; i < n; i++ {
+		t := r.targets[(r.turn+i)%n]
+		if t.next.After(now) {
+			continue
+		}
+		b, err := r.runFrom(t, &t.prevKey)
+		r.compact(t, now)
+		t.next = r.now().Add(t.schedule.next(b, err))
+	}
+	if n > 0 {
+		r.turn = (r.turn + 1) % n
+	}
+}
 
-					// The flag if the session is expired
-					isExpired = false
+// sweep checks all sessions of all targets, invoking a batch
+// of each unfinished target in turn.
+func (r *Reaper) sweep(ctx context.Context) {
+	prevKeys := make([][]byte, len(r.targets))
+	finished := make([]bool, len(r.targets))
+	for remaining := len(r.targets); remaining > 0; {
+		for i, t := range r.targets {
+			if finished[i] {
+				continue
+			}
+			if b, err := r.runFrom(t, &prevKeys[i]); err != nil || b.done {
+				finished[i] = true
+				remaining--
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.stopC:
+				return
+			default:
+			}
+		}
+	}
+}
 
-					session, err := shared.Session(v)
-					if err != nil {
-						// Just remove the session with the invalid session data.
-						// Log the error first.
-						log.Printf("boltstore: removing session from database with invalid value: %v", err)
-						isExpired = true
-					} else if shared.Expired(session) {
-						isExpired = true
-					}
+// runFrom invokes a batch of the target from the key where the previous
+// batch stopped and records its result.
+func (r *Reaper) runFrom(t *target, prevKey *[]byte) (batch, error) {
+	var b batch
+	var err error
+	switch {
+	case t.options.Partitioned:
+		b, err = reapPartitions(t.database(), t.options)
+	case t.options.UseExpiryIndex:
+		b, err = reapIndexed(t.database(), t.options)
+	default:
+		b, *prevKey, err = reapScan(t.database(), t.options, *prevKey)
+	}
+	if err == nil && t.options.Archive && t.options.Retention > 0 {
+		b.purged, err = purgeArchive(t.database(), t.options)
+	}
+	if err != nil {
+		log.Printf("boltstore: remove expired sessions from bucket %s error: %v", t.options.BucketName, err)
+	}
 
-					if isExpired {
-						// Copy the byte slice key, because this data is
-						// not safe outside of this transaction.
-						temp := make([]byte, len(k))
-						copy(temp, k)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t.stats.Scanned += uint64(b.scanned)
+	t.stats.Deleted += uint64(b.deleted)
+	t.stats.Corrupt += uint64(b.corrupt)
+	t.stats.Archived += uint64(b.archived)
+	t.stats.Purged += uint64(b.purged)
+	t.stats.LastRun = t.options.Clock.Now()
+	if err != nil {
+		t.stats.LastError = err
+	}
 
-						// Add it to the expired sessios keys slice
-						expiredSessionKeys = append(expiredSessionKeys, temp)
-					}
+	return b, err
+}
+
+// newTarget creates and returns a target which runs first
+// after its interval from now.
+func newTarget(t Target, now time.Time) *target {
+	t.Options.setDefault()
+	if t.Options.ArchiveDB == t.DB {
+		// Archive sessions in the same transaction.
+		t.Options.ArchiveDB = nil
+	}
+	s := newSchedule(t.Options)
+	return &target{
+		db:       t.DB,
+		options:  t.Options,
+		schedule: s,
+		next:     now.Add(s.interval),
+	}
+}
+
+// reapScan checks at most options.BatchSize sessions from the key next to
+// prevKey and removes expired ones. The key to start from next time is
+// returned.
+func reapScan(db *bolt.DB, options Options, prevKey []byte) (batch, []byte, error) {
+	var b batch
 
-					if options.BatchSize == i {
-						// Store the current key to the previous key.
-						// Copy the byte slice key, because this data is
-						// not safe outside of this transaction.
-						prevKey = make([]byte, len(k))
-						copy(prevKey, k)
-						return nil
+	// This slice is a buffer to save all expired session keys.
+	expiredSessionKeys := make([][]byte, 0)
+	// This slice is a buffer to save the reasons of the expiration.
+	reasons := make([]string, 0)
+	// These slices are buffers to save all corrupt session keys
+	// and their errors.
+	corruptSessionKeys := make([][]byte, 0)
+	corruptSessionErrs := make([]error, 0)
+
+	// Start a bolt read transaction.
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(options.BucketName)
+		if bucket == nil {
+			b.done = true
+			return nil
+		}
+
+		meta := tx.Bucket(options.MetaBucketName)
+
+		c := bucket.Cursor()
+
+		var isExpired bool
+
+		for k, v := c.Seek(prevKey); ; k, v = c.Next() {
+			// If we hit the end of our sessions then
+			// exit and start over next time.
+			if k == nil {
+				prevKey = nil
+				b.done = true
+				return nil
+			}
+
+			// Skip nested buckets.
+			if v == nil {
+				continue
+			}
+
+			b.scanned++
+
+			// The flag if the session is expired
+			isExpired = false
+
+			session, err := shared.Session(v)
+			if err != nil {
+				// Move the session with the invalid session data
+				// to the quarantine bucket. Log the error first.
+				log.Printf("boltstore: quarantining session with invalid value: %v", err)
+				corruptSessionKeys = append(corruptSessionKeys, copyBytes(k))
+				corruptSessionErrs = append(corruptSessionErrs, err)
+				b.corrupt++
+			} else if shared.ExpiredAt(session, options.Clock.Now(), options.ClockSkew) {
+				isExpired = true
+				reasons = append(reasons, shared.ArchiveReasonExpired)
+			} else if shared.Revoked(meta, session) {
+				isExpired = true
+				reasons = append(reasons, shared.ArchiveReasonRevoked)
+			}
+
+			if isExpired {
+				// Copy the byte slice key, because this data is
+				// not safe outside of this transaction.
+				temp := make([]byte, len(k))
+				copy(temp, k)
+
+				// Add it to the expired sessios keys slice
+				expiredSessionKeys = append(expiredSessionKeys, temp)
+			}
+
+			if options.BatchSize == b.scanned {
+				// Store the current key to the previous key.
+				// Copy the byte slice key, because this data is
+				// not safe outside of this transaction.
+				prevKey = make([]byte, len(k))
+				copy(prevKey, k)
+				return nil
+			}
+		}
+	})
+
+	if err != nil {
+		return b, prevKey, err
+	}
+
+	if len(expiredSessionKeys) > 0 || len(corruptSessionKeys) > 0 {
+		// Remove the expired sessions from the database
+		err = db.Update(func(txu *bolt.Tx) error {
+			// Get the bucket
+			bkt := txu.Bucket(options.BucketName)
+			if bkt == nil {
+				return nil
+			}
+
+			index := txu.Bucket(options.ExpiryIndexBucketName)
+			meta := txu.Bucket(options.MetaBucketName)
+
+			// Quarantine all corrupt sessions in the slice.
+			if err := quarantine(txu, options, corruptSessionKeys, corruptSessionErrs); err != nil {
+				return err
+			}
+
+			// Check the expired sessions in the slice again,
+			// because they may have been renewed since they were read.
+			var records []archived
+			var removedKeys [][]byte
+			for i, key := range expiredSessionKeys {
+				v := bkt.Get(key)
+				if v == nil {
+					continue
+				}
+				// Copy the session data, because the callback
+				// may modify the database.
+				data := copyBytes(v)
+				session, err := shared.Session(data)
+				if err != nil || !(shared.ExpiredAt(session, options.Clock.Now(), options.ClockSkew) || shared.Revoked(meta, session)) {
+					continue
+				}
+				if options.OnExpire != nil {
+					if err := options.OnExpire(txu, key, session); err != nil {
+						return err
 					}
 				}
-			})
+				if options.Archive {
+					records = append(records, archived{key, data, reasons[i]})
+				}
+				removedKeys = append(removedKeys, key)
+			}
+
+			// Archive the expired sessions first.
+			if err := archive(txu, options, records); err != nil {
+				return err
+			}
+			b.archived = len(records)
+
+			// Remove the expired sessions
+			for _, key := range removedKeys {
+				if err := shared.DeleteSession(bkt, index, key); err != nil {
+					return err
+				}
+			}
+			b.deleted = len(removedKeys) + len(corruptSessionKeys)
+
+			return nil
+		})
+
+		if err != nil {
+			return b, prevKey, err
+		}
+
+		b.full = len(expiredSessionKeys)+len(corruptSessionKeys) == options.BatchSize
+	}
+
+	return b, prevKey, nil
+}
+
+// reapIndexed removes at most options.BatchSize expired sessions
+// found through the expiry index.
+func reapIndexed(db *bolt.DB, options Options) (batch, error) {
+	var b batch
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(options.BucketName)
+		index := tx.Bucket(options.ExpiryIndexBucketName)
+		if bucket == nil || index == nil {
+			return nil
+		}
 
+		now := options.Clock.Now().Add(-options.ClockSkew).Unix()
+
+		// Collect the expired index entries. Copy the byte slice keys,
+		// because the index is modified after this.
+		var entries [][]byte
+		c := index.Cursor()
+		for k, _ := c.First(); k != nil && len(entries) < options.BatchSize; k, _ = c.Next() {
+			if expiresAt, _ := shared.ParseExpiryIndexKey(k); expiresAt > now {
+				break
+			}
+			entries = append(entries, append([]byte{}, k...))
+		}
+
+		var records []archived
+		for _, k := range entries {
+			b.scanned++
+			if err := index.Delete(k); err != nil {
+				return err
+			}
+			// Remove the session only if the entry is not stale.
+			expiresAt, key := shared.ParseExpiryIndexKey(k)
+			v := bucket.Get(key)
+			if v == nil {
+				continue
+			}
+			// Copy the session data, because the callback
+			// may modify the database.
+			data := copyBytes(v)
+			session, err := shared.Session(data)
 			if err != nil {
-				log.Printf("boltstore: obtain expired sessions error: %v", err)
+				log.Printf("boltstore: quarantining session with invalid value: %v", err)
+				if err := quarantine(tx, options, [][]byte{key}, []error{err}); err != nil {
+					return err
+				}
+				b.corrupt++
+				b.deleted++
+				continue
+			}
+			if session.GetExpiresAt() != expiresAt {
+				continue
 			}
+			if options.OnExpire != nil {
+				if err := options.OnExpire(tx, key, session); err != nil {
+					return err
+				}
+			}
+			if options.Archive {
+				records = append(records, archived{key, data, shared.ArchiveReasonExpired})
+			}
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+			b.deleted++
+		}
 
-			if len(expiredSessionKeys) > 0 {
-				// Remove the expired sessions from the database
-				err = db.Update(func(txu *bolt.Tx) error {
-					// Get the bucket
-					b := txu.Bucket(options.BucketName)
-					if b == nil {
-						return nil
-					}
+		b.archived = len(records)
+		return archive(tx, options, records)
+	})
+	b.full = b.scanned == options.BatchSize
+	b.done = !b.full
+	return b, err
+}
 
-					// Remove all expired sessions in the slice
-					for _, key := range expiredSessionKeys {
-						err = b.Delete(key)
-						if err != nil {
+// reapPartitions drops at most options.BatchSize expired partitions.
+func reapPartitions(db *bolt.DB, options Options) (batch, error) {
+	var b batch
+	var partitions int
+	err := db.Update(func(tx *bolt.Tx) error {
+		var records []archived
+		var fn func(k, v []byte) error
+		if options.Archive || options.OnExpire != nil {
+			fn = func(k, v []byte) error {
+				// Copy the key and the session data, because
+				// the callback may modify the database.
+				key, data := copyBytes(k), copyBytes(v)
+				if options.OnExpire != nil {
+					if session, err := shared.Session(data); err == nil {
+						if err := options.OnExpire(tx, key, session); err != nil {
 							return err
 						}
 					}
+				}
+				if options.Archive {
+					records = append(records, archived{key, data, shared.ArchiveReasonExpired})
+				}
+				return nil
+			}
+		}
+		var err error
+		b.deleted, partitions, err = shared.DropExpiredPartitions(tx, shared.PartitionedLayout{
+			BucketName:               options.BucketName,
+			PartitionIndexBucketName: options.PartitionIndexBucketName,
+		}, options.Clock.Now().Add(-options.ClockSkew).Unix(), options.BatchSize, fn)
+		if err != nil {
+			return err
+		}
+		b.archived = len(records)
+		return archive(tx, options, records)
+	})
+	b.scanned = b.deleted
+	b.full = partitions == options.BatchSize
+	b.done = !b.full
+	return b, err
+}
 
-					return nil
-				})
+// archived represents a session data which is moved to the archive bucket.
+type archived struct {
+	key    []byte
+	data   []byte
+	reason string
+}
 
-				if err != nil {
-					log.Printf("boltstore: remove expired sessions error: %v", err)
-				}
+// archive stores the session data in the archive bucket. They are stored
+// in the transaction unless options.ArchiveDB is set.
+func archive(tx *bolt.Tx, options Options, records []archived) error {
+	if len(records) == 0 {
+		return nil
+	}
+	put := func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(options.ArchiveBucketName)
+		if err != nil {
+			return err
+		}
+		now := options.Clock.Now().Unix()
+		for _, rec := range records {
+			if err := shared.Archive(bucket, rec.key, rec.data, rec.reason, now); err != nil {
+				return err
 			}
 		}
+		return nil
 	}
+	if options.ArchiveDB == nil {
+		return put(tx)
+	}
+	return options.ArchiveDB.Update(put)
+}
+
+// purgeArchive removes at most options.BatchSize sessions archived
+// before options.Retention.
+func purgeArchive(db *bolt.DB, options Options) (int, error) {
+	if options.ArchiveDB != nil {
+		db = options.ArchiveDB
+	}
+	var n int
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(options.ArchiveBucketName)
+		if bucket == nil {
+			return nil
+		}
+		var err error
+		n, err = shared.PurgeArchive(bucket, options.Clock.Now().Add(-options.Retention).Unix(), options.BatchSize)
+		return err
+	})
+	return n, err
+}
+
+// quarantine moves the session data of the keys to the quarantine bucket
+// with their errors.
+func quarantine(tx *bolt.Tx, options Options, keys [][]byte, errs []error) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	bucket, err := tx.CreateBucketIfNotExists(options.QuarantineBucketName)
+	if err != nil {
+		return err
+	}
+	l := shared.FlatLayout{
+		BucketName:            options.BucketName,
+		ExpiryIndexBucketName: options.ExpiryIndexBucketName,
+	}
+	now := options.Clock.Now().Unix()
+	for i, key := range keys {
+		data := l.Get(tx, key)
+		if data == nil {
+			continue
+		}
+		if err := shared.Quarantine(tx, l, bucket, key, copyBytes(data), errs[i], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBytes returns a copy of the byte slice.
+func copyBytes(b []byte) []byte {
+	return append([]byte{}, b...)
 }
@@ -1,15 +1,305 @@
 package reaper
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gogo/protobuf/proto"
+	"io/ioutil"
+	"os"
+	"reflect"
 	"testing"
 	"time"
 
 	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/boltstoretest"
 	"github.com/yosssi/boltstore/shared"
+	"github.com/yosssi/boltstore/shared/protobuf"
 )
 
+// runReaper runs a reaper for the duration.
+func runReaper(db *bolt.DB, options Options, d time.Duration) {
+	r := New(db, options)
+	r.Start(context.Background())
+	time.Sleep(d)
+	r.Stop(context.Background())
+}
+
+func TestReaper_Stop(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	// When the reaper is not started
+	r := New(db, Options{})
+	if err := r.Stop(context.Background()); err != nil {
+		t.Error(err.Error())
+	}
+
+	// When the reaper is started
+	r.Start(context.Background())
+	r.Start(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Stop(ctx); err != nil {
+		t.Error(err.Error())
+	}
+	if err := r.Stop(ctx); err != nil {
+		t.Error(err.Error())
+	}
+
+	// When the context of Start is done
+	r = New(db, Options{})
+	ctx, cancel = context.WithCancel(context.Background())
+	r.Start(ctx)
+	cancel()
+	select {
+	case <-r.doneC:
+	case <-time.After(time.Second):
+		t.Error("the reaper should finish when the context is done")
+	}
+
+	// When the deadline is exceeded
+	r = New(db, Options{})
+	r.started = true
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Stop(ctx); err != context.DeadlineExceeded {
+		t.Errorf("r.Stop should return %v (actual: %v)", context.DeadlineExceeded, err)
+	}
+}
+
+func TestReaper_RunNow(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	options := Options{
+		BucketName: []byte(fmt.Sprintf("runNowTest-%d", time.Now().UnixNano())),
+		BatchSize:  2,
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(options.BucketName)
+		if err != nil {
+			return err
+		}
+		for i, maxAge := range []int{-1, -1, -1, 60 * 60} {
+			data, err := proto.Marshal(shared.NewSession([]byte{}, maxAge))
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(fmt.Sprintf("test%d", i)), data); err != nil {
+				return err
+			}
+		}
+		return bucket.Put([]byte("test4"), []byte("test"))
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	r := New(db, options)
+	r.Start(context.Background())
+	defer r.Stop(context.Background())
+	r.RunNow()
+	r.RunNow()
+
+	deadline := time.Now().Add(time.Second)
+	for r.Stats().Deleted < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	stats := r.Stats()
+	if stats.Scanned < 5 || stats.Deleted != 4 || stats.Corrupt != 1 || stats.LastRun.IsZero() || stats.LastError != nil {
+		t.Errorf("r.Stats returned an invalid value (actual: %+v)", stats)
+	}
+}
+
+func TestOptions_Clock(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := boltstoretest.NewClock(now)
+	options := Options{
+		BucketName:    []byte(fmt.Sprintf("clockTest-%d", time.Now().UnixNano())),
+		Clock:         clock,
+		ClockSkew:     30 * time.Second,
+		CheckInterval: time.Minute,
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(options.BucketName)
+		if err != nil {
+			return err
+		}
+		data, err := proto.Marshal(shared.NewSessionAt([]byte{}, 60, now))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte("test"), data)
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	count := func() int {
+		var n int
+		db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket(options.BucketName).Stats().KeyN
+			return nil
+		})
+		return n
+	}
+
+	// When the session is expired within the grace period
+	o := options
+	o.setDefault()
+	clock.Advance(80 * time.Second)
+	if _, _, err := reapScan(db, o, nil); err != nil || count() != 1 {
+		t.Errorf("the session should not be removed within the grace period (actual: %d, %v)", count(), err)
+	}
+
+	// When the clock moves past the grace period, the reaper is invoked
+	// by the timer of the clock.
+	r := New(db, options)
+	r.Start(context.Background())
+	defer r.Stop(context.Background())
+	deadline := time.Now().Add(time.Second)
+	for r.Stats().Deleted < 1 && time.Now().Before(deadline) {
+		clock.Advance(time.Minute)
+		time.Sleep(10 * time.Millisecond)
+	}
+	stats := r.Stats()
+	if stats.Deleted != 1 || count() != 0 {
+		t.Errorf("the session should be removed (actual: %+v)", stats)
+	}
+	if stats.LastRun.Before(now) {
+		t.Errorf("r.Stats().LastRun should be the time of the clock (actual: %v)", stats.LastRun)
+	}
+}
+
+// putExpiredSessions puts n expired sessions to the bucket.
+func putExpiredSessions(db *bolt.DB, bucketName []byte, n int) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			data, err := proto.Marshal(shared.NewSession([]byte{}, -1))
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(fmt.Sprintf("test%d", i)), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func TestNewMulti(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	f, err := ioutil.TempFile("", "boltstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	db2, err := bolt.Open(f.Name(), 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.Close()
+
+	suffix := time.Now().UnixNano()
+	targets := []Target{
+		{DB: db, Options: Options{BucketName: []byte(fmt.Sprintf("multiTest1-%d", suffix)), BatchSize: 2}},
+		{DB: db, Options: Options{BucketName: []byte(fmt.Sprintf("multiTest2-%d", suffix)), BatchSize: 3}},
+		{DB: db2, Options: Options{BucketName: []byte(fmt.Sprintf("multiTest3-%d", suffix)), BatchSize: 10}},
+	}
+	for i, target := range targets {
+		if err := putExpiredSessions(target.DB, target.Options.BucketName, 5*(i+1)); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	r := NewMulti(targets...)
+	r.Start(context.Background())
+	defer r.Stop(context.Background())
+	r.RunNow()
+
+	deadline := time.Now().Add(time.Second)
+	for r.Stats().Deleted < 30 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := r.Stats(); stats.Deleted != 30 {
+		t.Errorf("r.Stats().Deleted should be %d (actual: %d)", 30, stats.Deleted)
+	}
+	for i, stats := range r.TargetStats() {
+		if stats.Deleted != uint64(5*(i+1)) {
+			t.Errorf("r.TargetStats()[%d].Deleted should be %d (actual: %d)", i, 5*(i+1), stats.Deleted)
+		}
+	}
+}
+
+func TestReaper_runDue(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	suffix := time.Now().UnixNano()
+	r := NewMulti(
+		Target{DB: db, Options: Options{BucketName: []byte(fmt.Sprintf("runDueTest1-%d", suffix)), BatchSize: 1, CheckInterval: time.Hour}},
+		Target{DB: db, Options: Options{BucketName: []byte(fmt.Sprintf("runDueTest2-%d", suffix)), BatchSize: 1, CheckInterval: time.Minute}},
+	)
+	for _, target := range r.targets {
+		if err := putExpiredSessions(db, target.options.BucketName, 3); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	now := time.Now()
+	if d := r.wait(now); d <= 0 || d > time.Minute {
+		t.Errorf("r.wait should return the interval of the earliest target (actual: %v)", d)
+	}
+
+	// Only the due target runs.
+	r.runDue(now.Add(2 * time.Minute))
+	if stats := r.TargetStats(); stats[0].Deleted != 0 || stats[1].Deleted != 1 {
+		t.Errorf("only the due target should run (actual: %+v)", stats)
+	}
+	if r.turn != 1 {
+		t.Errorf("r.turn should be %d (actual: %d)", 1, r.turn)
+	}
+
+	// All due targets run once each.
+	r.runDue(now.Add(2 * time.Hour))
+	if stats := r.TargetStats(); stats[0].Deleted != 1 || stats[1].Deleted != 2 {
+		t.Errorf("all due targets should run once each (actual: %+v)", stats)
+	}
+	if r.turn != 0 {
+		t.Errorf("r.turn should be %d (actual: %d)", 0, r.turn)
+	}
+
+	// A reaper without targets waits for the default interval.
+	if d := NewMulti().wait(now); d != shared.DefaultCheckInterval {
+		t.Errorf("wait should return %v (actual: %v)", shared.DefaultCheckInterval, d)
+	}
+}
+
 func TestRun(t *testing.T) {
 	db, err := bolt.Open("./sessions.db", 0666, nil)
 	if err != nil {
@@ -30,7 +320,7 @@ func TestQuit(t *testing.T) {
 	Quit(quitC, doneC)
 }
 
-func Test_reap(t *testing.T) {
+func TestReaper_loop(t *testing.T) {
 	// When the target bucket does not exist
 	db, err := bolt.Open("./sessions.db", 0666, nil)
 	if err != nil {
@@ -39,10 +329,7 @@ func Test_reap(t *testing.T) {
 	defer db.Close()
 	options := Options{}
 	options.setDefault()
-	quitC, doneC := make(chan struct{}), make(chan struct{})
-	go reap(db, options, quitC, doneC)
-	time.Sleep(2 * time.Second)
-	Quit(quitC, doneC)
+	runReaper(db, options, 2*time.Second)
 
 	// When no keys exist
 	bucketName := []byte(fmt.Sprintf("reapTest-%d", time.Now().Unix()))
@@ -56,9 +343,7 @@ func Test_reap(t *testing.T) {
 
 	options.BucketName = bucketName
 
-	go reap(db, options, quitC, doneC)
-	time.Sleep(2 * time.Second)
-	Quit(quitC, doneC)
+	runReaper(db, options, 2*time.Second)
 
 	// When shared.Session returns an error
 	err = db.Update(func(tx *bolt.Tx) error {
@@ -72,9 +357,7 @@ func Test_reap(t *testing.T) {
 	if err != nil {
 		t.Error(err.Error())
 	}
-	go reap(db, options, quitC, doneC)
-	time.Sleep(2 * time.Second)
-	Quit(quitC, doneC)
+	runReaper(db, options, 2*time.Second)
 
 	// When the target session is expired
 	err = db.Update(func(tx *bolt.Tx) error {
@@ -92,9 +375,7 @@ func Test_reap(t *testing.T) {
 	if err != nil {
 		t.Error(err.Error())
 	}
-	go reap(db, options, quitC, doneC)
-	time.Sleep(2 * time.Second)
-	Quit(quitC, doneC)
+	runReaper(db, options, 2*time.Second)
 
 	// When options.BatchSize == i
 	err = db.Update(func(tx *bolt.Tx) error {
@@ -125,9 +406,180 @@ func Test_reap(t *testing.T) {
 		t.Error(err.Error())
 	}
 	options.BatchSize = 3
-	go reap(db, options, quitC, doneC)
-	time.Sleep(2 * time.Second)
-	Quit(quitC, doneC)
+	runReaper(db, options, 2*time.Second)
+}
+
+func TestReaper_loop_revoked(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	options := Options{
+		BucketName:    []byte(fmt.Sprintf("reapRevokedTest-%d", time.Now().UnixNano())),
+		CheckInterval: 10 * time.Millisecond,
+	}
+	options.setDefault()
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(options.BucketName)
+		if err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucket(options.MetaBucketName)
+		if err != nil {
+			return err
+		}
+		data, err := proto.Marshal(shared.NewSession([]byte{}, 60*60))
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("test"), data); err != nil {
+			return err
+		}
+		return shared.SetRevocationEpoch(meta, "", time.Now().Add(time.Second).Unix())
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	runReaper(db, options, 100*time.Millisecond)
+
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(options.BucketName).Get([]byte("test")) != nil {
+			t.Error("the revoked session should be removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func Test_reapIndexed(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	options := Options{
+		BucketName: []byte(fmt.Sprintf("reapIndexedTest-%d", time.Now().UnixNano())),
+		BatchSize:  2,
+	}
+	options.setDefault()
+
+	// When the buckets do not exist
+	if _, err := reapIndexed(db, options); err != nil {
+		t.Error(err.Error())
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(options.BucketName)
+		if err != nil {
+			return err
+		}
+		index, err := tx.CreateBucket(options.ExpiryIndexBucketName)
+		if err != nil {
+			return err
+		}
+		for i, maxAge := range []int{-3, -2, -1, 60 * 60} {
+			if err := shared.PutSession(bucket, index, []byte(fmt.Sprintf("test%d", i)), shared.NewSession([]byte{}, maxAge)); err != nil {
+				return err
+			}
+		}
+		// Add a stale entry whose session has another expiration.
+		return index.Put(shared.ExpiryIndexKey(1, []byte("test3")), []byte{})
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := reapIndexed(db, options); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(options.BucketName)
+		if n := bucket.Stats().KeyN; n != 1 || bucket.Get([]byte("test3")) == nil {
+			t.Errorf("only the live session should remain (actual: %d sessions)", n)
+		}
+		if n := tx.Bucket(options.ExpiryIndexBucketName).Stats().KeyN; n != 1 {
+			t.Errorf("only the live index entry should remain (actual: %d entries)", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func Test_reapPartitions(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	options := Options{
+		BucketName:  []byte(fmt.Sprintf("reapPartitionsTest-%d", time.Now().UnixNano())),
+		Partitioned: true,
+	}
+	options.setDefault()
+	l := shared.PartitionedLayout{
+		BucketName:               options.BucketName,
+		PartitionIndexBucketName: options.PartitionIndexBucketName,
+		Partition:                time.Minute,
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := l.Init(tx); err != nil {
+			return err
+		}
+		if err := l.Put(tx, []byte("expired"), shared.NewSession([]byte{}, -2*60)); err != nil {
+			return err
+		}
+		return l.Put(tx, []byte("live"), shared.NewSession([]byte{}, 60*60))
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if b, err := reapPartitions(db, options); err != nil || b.deleted != 1 {
+		t.Errorf("reapPartitions should remove 1 session (actual: %+v, %v)", b, err)
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		if l.Get(tx, []byte("expired")) != nil {
+			t.Error("the expired session should be removed")
+		}
+		if l.Get(tx, []byte("live")) == nil {
+			t.Error("the live session should remain")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func ExampleNew() {
+	// Open a Bolt database.
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	// Close the database when the current function ends.
+	defer db.Close()
+
+	// Invoke a reaper which checks and removes expired sessions periodically.
+	r := New(db, Options{})
+	r.Start(context.Background())
+
+	// Terminate the reaper when the current function ends.
+	defer r.Stop(context.Background())
 }
 
 func ExampleRun() {
@@ -144,3 +596,408 @@ func ExampleRun() {
 	// Terminate the reaper when the current function ends.
 	defer Quit(Run(db, Options{}))
 }
+
+// archivedReasons returns the reasons of the archived sessions by their keys.
+func archivedReasons(db *bolt.DB, options Options) (map[string]string, error) {
+	reasons := make(map[string]string)
+	err := db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(options.ArchiveBucketName)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			session, err := shared.ArchivedSession(v)
+			if err != nil {
+				return err
+			}
+			_, key := shared.ParseArchiveKey(k)
+			reasons[string(key)] = session.GetReason()
+			return nil
+		})
+	})
+	return reasons, err
+}
+
+func Test_reapScan_archive(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	options := Options{
+		BucketName: []byte(fmt.Sprintf("archiveScanTest-%d", time.Now().UnixNano())),
+		Archive:    true,
+	}
+	options.setDefault()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(options.BucketName)
+		if err != nil {
+			return err
+		}
+		for key, maxAge := range map[string]int{"expired": -1, "revoked": 60 * 60, "live": 60 * 60} {
+			data, err := proto.Marshal(shared.NewSession([]byte{}, maxAge))
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		if err := bucket.Put([]byte("corrupt"), []byte("test")); err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucket(options.MetaBucketName)
+		if err != nil {
+			return err
+		}
+		return shared.SetRevocationEpoch(meta, "", time.Now().Unix()+1)
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	// Make the live session newer than the revocation epoch.
+	err = db.Update(func(tx *bolt.Tx) error {
+		session := shared.NewSession([]byte{}, 60*60)
+		createdAt := time.Now().Unix() + 2
+		session.CreatedAt = &createdAt
+		data, err := proto.Marshal(session)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(options.BucketName).Put([]byte("live"), data)
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	b, _, err := reapScan(db, options, nil)
+	if err != nil || b.deleted != 3 || b.archived != 2 || b.corrupt != 1 {
+		t.Errorf("reapScan should archive 2 sessions and quarantine 1 session (actual: %+v, %v)", b, err)
+	}
+	reasons, err := archivedReasons(db, options)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	expected := map[string]string{
+		"expired": shared.ArchiveReasonExpired,
+		"revoked": shared.ArchiveReasonRevoked,
+	}
+	if !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("the archived sessions should be %v (actual: %v)", expected, reasons)
+	}
+}
+
+func Test_reapIndexed_archiveDB(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	f, err := ioutil.TempFile("", "boltstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	archiveDB, err := bolt.Open(f.Name(), 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archiveDB.Close()
+
+	options := Options{
+		BucketName: []byte(fmt.Sprintf("archiveIndexedTest-%d", time.Now().UnixNano())),
+		Archive:    true,
+		ArchiveDB:  archiveDB,
+	}
+	options.setDefault()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(options.BucketName)
+		if err != nil {
+			return err
+		}
+		index, err := tx.CreateBucket(options.ExpiryIndexBucketName)
+		if err != nil {
+			return err
+		}
+		if err := shared.PutSession(bucket, index, []byte("expired"), shared.NewSession([]byte("values"), -1)); err != nil {
+			return err
+		}
+		return shared.PutSession(bucket, index, []byte("live"), shared.NewSession([]byte{}, 60*60))
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if b, err := reapIndexed(db, options); err != nil || b.deleted != 1 || b.archived != 1 {
+		t.Errorf("reapIndexed should archive 1 session (actual: %+v, %v)", b, err)
+	}
+
+	err = archiveDB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(options.ArchiveBucketName).ForEach(func(k, v []byte) error {
+			archived, err := shared.ArchivedSession(v)
+			if err != nil {
+				return err
+			}
+			session, err := shared.Session(archived.GetData())
+			if err != nil {
+				return err
+			}
+			if string(session.GetValues()) != "values" {
+				t.Errorf("the session data should be archived (actual: %s)", session.String())
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if reasons, err := archivedReasons(db, options); err != nil || len(reasons) != 0 {
+		t.Errorf("no session should be archived in the database of sessions (actual: %v, %v)", reasons, err)
+	}
+}
+
+func Test_reapPartitions_archive(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	options := Options{
+		BucketName:  []byte(fmt.Sprintf("archivePartitionsTest-%d", time.Now().UnixNano())),
+		Partitioned: true,
+		Archive:     true,
+	}
+	options.setDefault()
+	l := shared.PartitionedLayout{
+		BucketName:               options.BucketName,
+		PartitionIndexBucketName: options.PartitionIndexBucketName,
+		Partition:                time.Minute,
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := l.Init(tx); err != nil {
+			return err
+		}
+		return l.Put(tx, []byte("expired"), shared.NewSession([]byte{}, -2*60))
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if b, err := reapPartitions(db, options); err != nil || b.archived != 1 {
+		t.Errorf("reapPartitions should archive 1 session (actual: %+v, %v)", b, err)
+	}
+	reasons, err := archivedReasons(db, options)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if reasons["expired"] != shared.ArchiveReasonExpired {
+		t.Errorf("the expired session should be archived (actual: %v)", reasons)
+	}
+}
+
+func Test_purgeArchive(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	options := Options{
+		BucketName: []byte(fmt.Sprintf("purgeArchiveTest-%d", time.Now().UnixNano())),
+		Archive:    true,
+		Retention:  24 * time.Hour,
+	}
+	options.setDefault()
+
+	// When the archive bucket does not exist
+	if n, err := purgeArchive(db, options); err != nil || n != 0 {
+		t.Errorf("purgeArchive should return 0 (actual: %d, %v)", n, err)
+	}
+
+	now := time.Now()
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(options.ArchiveBucketName)
+		if err != nil {
+			return err
+		}
+		if err := shared.Archive(bucket, []byte("old"), []byte{}, shared.ArchiveReasonExpired, now.Add(-48*time.Hour).Unix()); err != nil {
+			return err
+		}
+		return shared.Archive(bucket, []byte("new"), []byte{}, shared.ArchiveReasonExpired, now.Unix())
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	r := New(db, options)
+	if b, err := r.runFrom(r.targets[0], &r.targets[0].prevKey); err != nil || b.purged != 1 {
+		t.Errorf("the old archived session should be purged (actual: %+v, %v)", b, err)
+	}
+	if stats := r.Stats(); stats.Purged != 1 {
+		t.Errorf("r.Stats().Purged should be %d (actual: %d)", 1, stats.Purged)
+	}
+	reasons, err := archivedReasons(db, options)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if _, ok := reasons["new"]; !ok || len(reasons) != 1 {
+		t.Errorf("only the new archived session should remain (actual: %v)", reasons)
+	}
+}
+
+func Test_reapScan_quarantine(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	options := Options{
+		BucketName: []byte(fmt.Sprintf("quarantineScanTest-%d", time.Now().UnixNano())),
+	}
+	options.setDefault()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(options.BucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte("corrupt"), []byte("test"))
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if b, _, err := reapScan(db, options, nil); err != nil || b.corrupt != 1 || b.deleted != 1 {
+		t.Errorf("reapScan should quarantine 1 session (actual: %+v, %v)", b, err)
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(options.BucketName).Get([]byte("corrupt")) != nil {
+			t.Error("the corrupt session should be removed from the bucket")
+		}
+		quarantined, err := shared.ArchivedSession(tx.Bucket(options.QuarantineBucketName).Get([]byte("corrupt")))
+		if err != nil {
+			return err
+		}
+		if string(quarantined.GetData()) != "test" || quarantined.GetReason() == "" || quarantined.GetArchivedAt() == 0 {
+			t.Errorf("the corrupt session should be quarantined with its error (actual: %s)", quarantined.String())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestOptions_OnExpire(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	for _, mode := range []string{"scan", "indexed", "partitioned"} {
+		options := Options{
+			BucketName:     []byte(fmt.Sprintf("onExpireTest-%s-%d", mode, time.Now().UnixNano())),
+			UseExpiryIndex: mode == "indexed",
+			Partitioned:    mode == "partitioned",
+		}
+		options.setDefault()
+		rowsName := append(append([]byte{}, options.BucketName...), "_rows"...)
+		var l shared.Layout = shared.FlatLayout{BucketName: options.BucketName, ExpiryIndexBucketName: options.ExpiryIndexBucketName}
+		if options.Partitioned {
+			l = shared.PartitionedLayout{BucketName: options.BucketName, PartitionIndexBucketName: options.PartitionIndexBucketName, Partition: time.Minute}
+		}
+		err = db.Update(func(tx *bolt.Tx) error {
+			if err := l.Init(tx); err != nil {
+				return err
+			}
+			rows, err := tx.CreateBucket(rowsName)
+			if err != nil {
+				return err
+			}
+			for key, maxAge := range map[string]int{"expired": -2 * 60, "live": 60 * 60} {
+				if err := l.Put(tx, []byte(key), shared.NewSession([]byte(key), maxAge)); err != nil {
+					return err
+				}
+				if err := rows.Put([]byte(key), []byte{}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error(err.Error())
+		}
+
+		// When the callback returns an error
+		options.OnExpire = func(tx *bolt.Tx, id []byte, session protobuf.Session) error {
+			return errors.New("test")
+		}
+		r := New(db, options)
+		if _, err := r.runFrom(r.targets[0], &r.targets[0].prevKey); err == nil || err.Error() != "test" {
+			t.Errorf("%s: the error of the callback should be returned (actual: %v)", mode, err)
+		}
+
+		// When the callback removes the related data
+		var ids []string
+		options.OnExpire = func(tx *bolt.Tx, id []byte, session protobuf.Session) error {
+			ids = append(ids, string(id)+":"+string(session.GetValues()))
+			return tx.Bucket(rowsName).Delete(id)
+		}
+		r = New(db, options)
+		if _, err := r.runFrom(r.targets[0], &r.targets[0].prevKey); err != nil {
+			t.Error(err.Error())
+		}
+		if fmt.Sprint(ids) != "[expired:expired]" {
+			t.Errorf("%s: the callback should be called for the expired session (actual: %v)", mode, ids)
+		}
+		err = db.View(func(tx *bolt.Tx) error {
+			rows := tx.Bucket(rowsName)
+			if rows.Get([]byte("expired")) != nil || rows.Get([]byte("live")) == nil {
+				t.Errorf("%s: only the row of the expired session should be removed", mode)
+			}
+			if l.Get(tx, []byte("expired")) != nil || l.Get(tx, []byte("live")) == nil {
+				t.Errorf("%s: only the expired session should be removed", mode)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error(err.Error())
+		}
+	}
+}
+
+func TestOptions_RememberTokens(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	defer db.Close()
+
+	options := Options{
+		BucketName:     []byte(fmt.Sprintf("rememberTest-%d", time.Now().UnixNano())),
+		RememberTokens: true,
+	}
+	if err := putExpiredSessions(db, shared.RememberBucketName(options.BucketName), 3); err != nil {
+		t.Error(err.Error())
+	}
+
+	r := New(db, options)
+	if len(r.targets) != 2 {
+		t.Fatalf("the reaper should have %d targets (actual: %d)", 2, len(r.targets))
+	}
+	if b, err := r.runFrom(r.targets[1], &r.targets[1].prevKey); err != nil || b.deleted != 3 {
+		t.Errorf("the expired tokens should be deleted (actual: %+v, %v)", b, err)
+	}
+	if r := New(db, Options{BucketName: options.BucketName}); len(r.targets) != 1 {
+		t.Errorf("the reaper should have %d target (actual: %d)", 1, len(r.targets))
+	}
+}
@@ -0,0 +1,56 @@
+package reaper
+
+import (
+	"math/rand"
+	"time"
+)
+
+// schedule decides the interval between the reaper's invocations.
+type schedule struct {
+	options  Options
+	interval time.Duration
+	rand     *rand.Rand
+}
+
+// next returns the interval until the next invocation
+// after the batch finished with the error.
+func (s *schedule) next(b batch, err error) time.Duration {
+	if !s.options.Adaptive {
+		return s.options.CheckInterval
+	}
+
+	switch {
+	case err != nil:
+		// Back off on errors.
+		s.interval *= 2
+	case b.full:
+		// Hurry while batches are full of expired sessions.
+		s.interval /= 2
+	case b.deleted == 0 && b.done:
+		// Slow down while there is nothing to remove.
+		s.interval *= 2
+	default:
+		s.interval = s.options.CheckInterval
+	}
+
+	if s.interval < s.options.MinCheckInterval {
+		s.interval = s.options.MinCheckInterval
+	}
+	if s.interval > s.options.MaxCheckInterval {
+		s.interval = s.options.MaxCheckInterval
+	}
+
+	if s.options.Jitter <= 0 {
+		return s.interval
+	}
+	return time.Duration(float64(s.interval) * (1 + s.options.Jitter*(2*s.rand.Float64()-1)))
+}
+
+// newSchedule creates and returns a schedule.
+func newSchedule(options Options) *schedule {
+	return &schedule{
+		options:  options,
+		interval: options.CheckInterval,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
@@ -0,0 +1,53 @@
+package reaper
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSchedule_next(t *testing.T) {
+	// When the reaper is not adaptive
+	options := Options{CheckInterval: time.Minute}
+	options.setDefault()
+	s := newSchedule(options)
+	if d := s.next(batch{full: true}, nil); d != time.Minute {
+		t.Errorf("s.next should return %s (actual: %s)", time.Minute, d)
+	}
+
+	// When the reaper is adaptive
+	options.Adaptive = true
+	options.Jitter = -1
+	options.MinCheckInterval = 10 * time.Second
+	options.MaxCheckInterval = 4 * time.Minute
+	s = newSchedule(options)
+
+	for _, c := range []struct {
+		b        batch
+		err      error
+		expected time.Duration
+	}{
+		{batch{deleted: 100, full: true}, nil, 30 * time.Second},
+		{batch{deleted: 100, full: true}, nil, 15 * time.Second},
+		{batch{deleted: 100, full: true}, nil, 10 * time.Second},
+		{batch{deleted: 10, done: true}, nil, time.Minute},
+		{batch{done: true}, nil, 2 * time.Minute},
+		{batch{done: false}, nil, time.Minute},
+		{batch{}, errors.New("test"), 2 * time.Minute},
+		{batch{}, errors.New("test"), 4 * time.Minute},
+		{batch{}, errors.New("test"), 4 * time.Minute},
+	} {
+		if d := s.next(c.b, c.err); d != c.expected {
+			t.Errorf("s.next should return %s (actual: %s)", c.expected, d)
+		}
+	}
+
+	// When the jitter is set
+	options.Jitter = 0.5
+	s = newSchedule(options)
+	for i := 0; i < 100; i++ {
+		if d := s.next(batch{deleted: 1}, nil); d < 30*time.Second || 90*time.Second < d {
+			t.Errorf("s.next should return a duration within the jitter (actual: %s)", d)
+		}
+	}
+}
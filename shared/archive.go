@@ -0,0 +1,76 @@
+package shared
+
+import (
+	"encoding/binary"
+
+	"github.com/boltdb/bolt"
+	"github.com/gogo/protobuf/proto"
+	"github.com/yosssi/boltstore/shared/protobuf"
+)
+
+// Reasons why sessions are archived
+const (
+	ArchiveReasonExpired = "expired"
+	ArchiveReasonRevoked = "revoked"
+)
+
+// ArchiveBucketName returns the name of the archive bucket
+// which is placed next to the bucket of the given name.
+func ArchiveBucketName(bucketName []byte) []byte {
+	return append(append([]byte{}, bucketName...), ArchiveBucketSuffix...)
+}
+
+// ArchiveKey returns the key of the archived session which consists of
+// the big-endian archiving time and the key of the session, so that
+// archived sessions sort by the archiving time.
+func ArchiveKey(archivedAt int64, key []byte) []byte {
+	return ExpiryIndexKey(archivedAt, key)
+}
+
+// ParseArchiveKey returns the archiving time and the key of the session
+// of the archived session.
+func ParseArchiveKey(k []byte) (int64, []byte) {
+	return ParseExpiryIndexKey(k)
+}
+
+// Archive stores the session data of the key in the archive bucket
+// with the reason and the archiving time.
+func Archive(archive *bolt.Bucket, key, data []byte, reason string, archivedAt int64) error {
+	v, err := proto.Marshal(&protobuf.ArchivedSession{
+		Data:       data,
+		Reason:     &reason,
+		ArchivedAt: &archivedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return archive.Put(ArchiveKey(archivedAt, key), v)
+}
+
+// ArchivedSession converts the byte slice to the archived session struct value.
+func ArchivedSession(data []byte) (protobuf.ArchivedSession, error) {
+	session := protobuf.ArchivedSession{}
+	err := proto.Unmarshal(data, &session)
+	return session, err
+}
+
+// PurgeArchive removes at most limit sessions archived at or before
+// the given Unix time and returns the number of removed sessions.
+func PurgeArchive(archive *bolt.Bucket, before int64, limit int) (int, error) {
+	// Collect the keys first, because the bucket must not be
+	// modified while iterating over it.
+	var keys [][]byte
+	c := archive.Cursor()
+	for k, _ := c.First(); k != nil && len(keys) < limit; k, _ = c.Next() {
+		if len(k) < 8 || int64(binary.BigEndian.Uint64(k)) > before {
+			break
+		}
+		keys = append(keys, append([]byte{}, k...))
+	}
+	for _, k := range keys {
+		if err := archive.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
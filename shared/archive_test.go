@@ -0,0 +1,63 @@
+package shared
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestArchiveBucketName(t *testing.T) {
+	if actual := string(ArchiveBucketName([]byte("sessions"))); actual != "sessions_archive" {
+		t.Errorf("ArchiveBucketName() should return %s (actual: %s)", "sessions_archive", actual)
+	}
+}
+
+func TestParseArchiveKey(t *testing.T) {
+	archivedAt, key := ParseArchiveKey(ArchiveKey(100, []byte("test")))
+	if archivedAt != 100 || string(key) != "test" {
+		t.Errorf("ParseArchiveKey() should return 100, test (actual: %d, %s)", archivedAt, key)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	db, closeDB := openTestDB(t)
+	defer closeDB()
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		archive, err := tx.CreateBucket([]byte("sessions_archive"))
+		if err != nil {
+			return err
+		}
+		for i, archivedAt := range []int64{100, 200, 300} {
+			if err := Archive(archive, []byte{byte(i)}, []byte("data"), ArchiveReasonExpired, archivedAt); err != nil {
+				return err
+			}
+		}
+
+		v := archive.Get(ArchiveKey(200, []byte{1}))
+		session, err := ArchivedSession(v)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(session.GetData(), []byte("data")) || session.GetReason() != ArchiveReasonExpired || session.GetArchivedAt() != 200 {
+			t.Errorf("the archived session is invalid (actual: %s)", session.String())
+		}
+
+		// When the limit is reached
+		if n, err := PurgeArchive(archive, 300, 1); err != nil || n != 1 {
+			t.Errorf("PurgeArchive() should return 1 (actual: %d, %v)", n, err)
+		}
+		// When some sessions are archived after the time
+		if n, err := PurgeArchive(archive, 250, 10); err != nil || n != 1 {
+			t.Errorf("PurgeArchive() should return 1 (actual: %d, %v)", n, err)
+		}
+		if n := keyN(archive); n != 1 {
+			t.Errorf("the archive should have 1 session (actual: %d)", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,58 @@
+package shared
+
+import "time"
+
+// Clock represents a source of the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// TimerClock represents a clock which also creates timers. The reaper
+// uses the timers of its clock if the clock is a TimerClock.
+type TimerClock interface {
+	Clock
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer represents a timer which sends the time on its channel
+// when it fires.
+type Timer interface {
+	// C returns the channel on which the time is sent.
+	C() <-chan time.Time
+	// Reset changes the timer to fire after the duration.
+	Reset(d time.Duration) bool
+	// Stop prevents the timer from firing.
+	Stop() bool
+}
+
+// SystemClock represents the clock of the system.
+type SystemClock struct{}
+
+// Now returns the current local time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer creates a timer which fires after the duration.
+func (SystemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+// NewTimer creates a timer of the clock if it is a TimerClock,
+// or a timer of the system otherwise.
+func NewTimer(c Clock, d time.Duration) Timer {
+	if tc, ok := c.(TimerClock); ok {
+		return tc.NewTimer(d)
+	}
+	return SystemClock{}.NewTimer(d)
+}
+
+// systemTimer represents a timer of the system.
+type systemTimer struct {
+	*time.Timer
+}
+
+// C returns the channel on which the time is sent.
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -0,0 +1,36 @@
+package shared
+
+import (
+	"testing"
+	"time"
+)
+
+// testClock is a clock which is not a TimerClock.
+type testClock struct{}
+
+func (testClock) Now() time.Time {
+	return time.Unix(0, 0)
+}
+
+func TestSystemClock(t *testing.T) {
+	before := time.Now()
+	now := SystemClock{}.Now()
+	if now.Before(before) || now.After(time.Now()) {
+		t.Errorf("SystemClock.Now() should return the current time (actual: %v)", now)
+	}
+}
+
+func TestNewTimer(t *testing.T) {
+	for _, c := range []Clock{SystemClock{}, testClock{}} {
+		timer := NewTimer(c, time.Millisecond)
+		select {
+		case <-timer.C():
+		case <-time.After(time.Second):
+			t.Errorf("the timer of %T should fire", c)
+		}
+		timer.Reset(time.Hour)
+		if !timer.Stop() {
+			t.Errorf("timer.Stop() of %T should return true for the active timer", c)
+		}
+	}
+}
@@ -11,10 +11,55 @@ const (
 // Defaults for store.Options
 const (
 	DefaultBucketName = "sessions"
+	// MetaBucketSuffix is appended to the name of the bucket which
+	// contains sessions to make the name of its meta bucket.
+	MetaBucketSuffix = "_meta"
+	// ExpiryIndexBucketSuffix is appended to the name of the bucket which
+	// contains sessions to make the name of its expiry index bucket.
+	ExpiryIndexBucketSuffix = "_expiry"
+	// PartitionIndexBucketSuffix is appended to the name of the bucket which
+	// contains sessions to make the name of its partition index bucket.
+	PartitionIndexBucketSuffix = "_partitions"
+	// ArchiveBucketSuffix is appended to the name of the bucket which
+	// contains sessions to make the name of its archive bucket.
+	ArchiveBucketSuffix = "_archive"
+	// QuarantineBucketSuffix is appended to the name of the bucket which
+	// contains sessions to make the name of its quarantine bucket.
+	QuarantineBucketSuffix = "_quarantine"
+	// RememberBucketSuffix is appended to the name of the bucket which
+	// contains sessions to make the name of its remember-me token bucket.
+	RememberBucketSuffix = "_remember"
+)
+
+// InlinePrefix prefixes an encoded session data which is carried by
+// the transport itself instead of an encoded session ID
+// (store.Config.InlineMaxSize). It is not used by the encoding of
+// securecookie.
+const InlinePrefix = "~"
+
+// Defaults for store.RememberOptions
+const (
+	DefaultRememberCookieName  = "remember"
+	DefaultRememberMaxAge      = 60 * 60 * 24 * 365 // 365days
+	DefaultRememberGracePeriod = 30 * time.Second
+)
+
+// Defaults for store.BearerTransport
+const (
+	DefaultResponseHeader = "X-Session-Token"
 )
 
 // Defaults for reaper.Options
 const (
-	DefaultBatchSize     = 100
-	DefaultCheckInterval = time.Minute
+	DefaultBatchSize        = 100
+	DefaultCheckInterval    = time.Minute
+	DefaultMinCheckInterval = time.Second
+	DefaultMaxCheckInterval = 10 * time.Minute
+	DefaultJitter           = 0.1
+	DefaultCompactInterval  = 24 * time.Hour
+)
+
+// Defaults for compact.Options
+const (
+	DefaultTxMaxSize = 64 * 1024 * 1024
 )
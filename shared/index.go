@@ -0,0 +1,92 @@
+package shared
+
+import (
+	"encoding/binary"
+
+	"github.com/boltdb/bolt"
+	"github.com/gogo/protobuf/proto"
+	"github.com/yosssi/boltstore/shared/protobuf"
+)
+
+// ExpiryIndexBucketName returns the name of the expiry index bucket
+// which is placed next to the bucket of the given name.
+func ExpiryIndexBucketName(bucketName []byte) []byte {
+	return append(append([]byte{}, bucketName...), ExpiryIndexBucketSuffix...)
+}
+
+// ExpiryIndexKey returns the key of the expiry index entry which consists
+// of the big-endian expiration and the key of the session, so that
+// the entries sort by the expiration.
+func ExpiryIndexKey(expiresAt int64, key []byte) []byte {
+	k := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(k, uint64(expiresAt))
+	copy(k[8:], key)
+	return k
+}
+
+// ParseExpiryIndexKey returns the expiration and the key of the session
+// of the expiry index entry.
+func ParseExpiryIndexKey(k []byte) (int64, []byte) {
+	if len(k) < 8 {
+		return 0, nil
+	}
+	return int64(binary.BigEndian.Uint64(k)), k[8:]
+}
+
+// PutSession stores the session data under the key and updates the expiry
+// index. The index is not updated if it is nil. Sessions which never
+// expire are not indexed.
+func PutSession(bucket, index *bolt.Bucket, key []byte, session *protobuf.Session) error {
+	data, err := proto.Marshal(session)
+	if err != nil {
+		return err
+	}
+	if err := deleteIndex(bucket, index, key); err != nil {
+		return err
+	}
+	if err := bucket.Put(key, data); err != nil {
+		return err
+	}
+	if index == nil || session.GetExpiresAt() <= 0 {
+		return nil
+	}
+	return index.Put(ExpiryIndexKey(*session.ExpiresAt, key), []byte{})
+}
+
+// DeleteSession removes the session data of the key and its expiry index
+// entry. The index is not updated if it is nil.
+func DeleteSession(bucket, index *bolt.Bucket, key []byte) error {
+	if err := deleteIndex(bucket, index, key); err != nil {
+		return err
+	}
+	return bucket.Delete(key)
+}
+
+// BuildExpiryIndex adds the expiry index entries of all sessions
+// in the bucket. Invalid session data is skipped.
+func BuildExpiryIndex(bucket, index *bolt.Bucket) error {
+	return bucket.ForEach(func(k, v []byte) error {
+		session, err := Session(v)
+		if err != nil || session.GetExpiresAt() <= 0 {
+			return nil
+		}
+		return index.Put(ExpiryIndexKey(*session.ExpiresAt, k), []byte{})
+	})
+}
+
+// deleteIndex removes the expiry index entry of the session
+// currently stored under the key.
+func deleteIndex(bucket, index *bolt.Bucket, key []byte) error {
+	if index == nil {
+		return nil
+	}
+	data := bucket.Get(key)
+	if data == nil {
+		return nil
+	}
+	session, err := Session(data)
+	if err != nil || session.GetExpiresAt() <= 0 {
+		return nil
+	}
+	return index.Delete(ExpiryIndexKey(*session.ExpiresAt, key))
+}
@@ -0,0 +1,116 @@
+package shared
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+// openTestDB opens a temporary database.
+func openTestDB(t *testing.T) (*bolt.DB, func()) {
+	f, err := ioutil.TempFile("", "boltstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	db, err := bolt.Open(f.Name(), 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.Remove(f.Name())
+	}
+}
+
+// keyN returns the number of keys in the bucket.
+func keyN(bucket *bolt.Bucket) int {
+	var n int
+	bucket.ForEach(func(k, v []byte) error {
+		n++
+		return nil
+	})
+	return n
+}
+
+func TestExpiryIndexBucketName(t *testing.T) {
+	if actual := string(ExpiryIndexBucketName([]byte("sessions"))); actual != "sessions_expiry" {
+		t.Errorf("ExpiryIndexBucketName() should return %s (actual: %s)", "sessions_expiry", actual)
+	}
+}
+
+func TestParseExpiryIndexKey(t *testing.T) {
+	expiresAt, key := ParseExpiryIndexKey(ExpiryIndexKey(100, []byte("test")))
+	if expiresAt != 100 || string(key) != "test" {
+		t.Errorf("ParseExpiryIndexKey() returned an invalid value (actual: %d, %s)", expiresAt, key)
+	}
+	if bytes.Compare(ExpiryIndexKey(100, []byte("b")), ExpiryIndexKey(256, []byte("a"))) >= 0 {
+		t.Error("expiry index keys should sort by the expiration")
+	}
+	if _, key := ParseExpiryIndexKey([]byte("x")); key != nil {
+		t.Errorf("ParseExpiryIndexKey() should return nil (actual: %s)", key)
+	}
+}
+
+func TestPutSession(t *testing.T) {
+	db, closeDB := openTestDB(t)
+	defer closeDB()
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte("sessions"))
+		if err != nil {
+			return err
+		}
+		index, err := tx.CreateBucket([]byte("sessions_expiry"))
+		if err != nil {
+			return err
+		}
+
+		// When the session is put
+		session := NewSession([]byte("test"), 60)
+		if err := PutSession(bucket, index, []byte("test"), session); err != nil {
+			return err
+		}
+		if index.Get(ExpiryIndexKey(*session.ExpiresAt, []byte("test"))) == nil {
+			t.Error("the index entry should be put")
+		}
+
+		// When the session is put again with another expiration
+		updated := NewSession([]byte("test"), 120)
+		if err := PutSession(bucket, index, []byte("test"), updated); err != nil {
+			return err
+		}
+		if keyN(index) != 1 || index.Get(ExpiryIndexKey(*updated.ExpiresAt, []byte("test"))) == nil {
+			t.Error("the index entry should be replaced")
+		}
+
+		// When the session is deleted
+		if err := DeleteSession(bucket, index, []byte("test")); err != nil {
+			return err
+		}
+		if bucket.Get([]byte("test")) != nil || keyN(index) != 0 {
+			t.Error("the session and its index entry should be removed")
+		}
+
+		// When the index is built
+		if err := PutSession(bucket, nil, []byte("test"), session); err != nil {
+			return err
+		}
+		if err := bucket.Put([]byte("invalid"), []byte("test")); err != nil {
+			return err
+		}
+		if err := BuildExpiryIndex(bucket, index); err != nil {
+			return err
+		}
+		if keyN(index) != 1 || index.Get(ExpiryIndexKey(*session.ExpiresAt, []byte("test"))) == nil {
+			t.Error("the index entry should be built")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,253 @@
+package shared
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gogo/protobuf/proto"
+	"github.com/yosssi/boltstore/shared/protobuf"
+)
+
+// Layout represents how sessions are laid out in a database.
+type Layout interface {
+	// Init creates the buckets used by the layout.
+	Init(tx *bolt.Tx) error
+	// Get returns the session data of the key. Nil is returned
+	// if there is no such session data.
+	Get(tx *bolt.Tx, key []byte) []byte
+	// Put stores the session data under the key.
+	Put(tx *bolt.Tx, key []byte, session *protobuf.Session) error
+	// Delete removes the session data of the key.
+	Delete(tx *bolt.Tx, key []byte) error
+	// ForEach calls the function for each session data.
+	ForEach(tx *bolt.Tx, fn func(k, v []byte) error) error
+}
+
+// FlatLayout stores all sessions in a single bucket and indexes them
+// by their expiration in another bucket.
+type FlatLayout struct {
+	// BucketName represents the name of the bucket which contains sessions.
+	BucketName []byte
+	// ExpiryIndexBucketName represents the name of the expiry index bucket.
+	ExpiryIndexBucketName []byte
+}
+
+// Init creates the buckets and builds the expiry index of the existing
+// sessions if the index bucket does not exist.
+func (l FlatLayout) Init(tx *bolt.Tx) error {
+	bucket, err := tx.CreateBucketIfNotExists(l.BucketName)
+	if err != nil {
+		return err
+	}
+	if tx.Bucket(l.ExpiryIndexBucketName) != nil {
+		return nil
+	}
+	index, err := tx.CreateBucket(l.ExpiryIndexBucketName)
+	if err != nil {
+		return err
+	}
+	return BuildExpiryIndex(bucket, index)
+}
+
+// Get returns the session data of the key.
+func (l FlatLayout) Get(tx *bolt.Tx, key []byte) []byte {
+	bucket := tx.Bucket(l.BucketName)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.Get(key)
+}
+
+// Put stores the session data under the key.
+func (l FlatLayout) Put(tx *bolt.Tx, key []byte, session *protobuf.Session) error {
+	return PutSession(tx.Bucket(l.BucketName), tx.Bucket(l.ExpiryIndexBucketName), key, session)
+}
+
+// Delete removes the session data of the key.
+func (l FlatLayout) Delete(tx *bolt.Tx, key []byte) error {
+	return DeleteSession(tx.Bucket(l.BucketName), tx.Bucket(l.ExpiryIndexBucketName), key)
+}
+
+// ForEach calls the function for each session data.
+func (l FlatLayout) ForEach(tx *bolt.Tx, fn func(k, v []byte) error) error {
+	bucket := tx.Bucket(l.BucketName)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(fn)
+}
+
+// PartitionedLayout stores sessions in sub-buckets of the bucket, each of
+// which contains sessions expiring within a period of Partition, so that
+// an expired partition can be dropped as a whole. Another bucket maps
+// the keys of sessions to their partitions.
+type PartitionedLayout struct {
+	// BucketName represents the name of the bucket which contains
+	// the partitions.
+	BucketName []byte
+	// PartitionIndexBucketName represents the name of the bucket which maps
+	// the keys of sessions to their partitions.
+	PartitionIndexBucketName []byte
+	// Partition represents the period of a partition.
+	Partition time.Duration
+}
+
+// Init creates the buckets.
+func (l PartitionedLayout) Init(tx *bolt.Tx) error {
+	if _, err := tx.CreateBucketIfNotExists(l.BucketName); err != nil {
+		return err
+	}
+	_, err := tx.CreateBucketIfNotExists(l.PartitionIndexBucketName)
+	return err
+}
+
+// Get returns the session data of the key.
+func (l PartitionedLayout) Get(tx *bolt.Tx, key []byte) []byte {
+	partition := l.partition(tx, key)
+	if partition == nil {
+		return nil
+	}
+	return partition.Get(key)
+}
+
+// Put stores the session data under the key in the partition
+// of its expiration.
+func (l PartitionedLayout) Put(tx *bolt.Tx, key []byte, session *protobuf.Session) error {
+	data, err := proto.Marshal(session)
+	if err != nil {
+		return err
+	}
+	name := PartitionName(session.GetExpiresAt(), l.Partition)
+	if prev := tx.Bucket(l.PartitionIndexBucketName).Get(key); prev != nil && !bytes.Equal(prev, name) {
+		if err := l.Delete(tx, key); err != nil {
+			return err
+		}
+	}
+	partition, err := tx.Bucket(l.BucketName).CreateBucketIfNotExists(name)
+	if err != nil {
+		return err
+	}
+	if err := partition.Put(key, data); err != nil {
+		return err
+	}
+	return tx.Bucket(l.PartitionIndexBucketName).Put(key, name)
+}
+
+// Delete removes the session data of the key.
+func (l PartitionedLayout) Delete(tx *bolt.Tx, key []byte) error {
+	if partition := l.partition(tx, key); partition != nil {
+		if err := partition.Delete(key); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(l.PartitionIndexBucketName).Delete(key)
+}
+
+// ForEach calls the function for each session data.
+func (l PartitionedLayout) ForEach(tx *bolt.Tx, fn func(k, v []byte) error) error {
+	bucket := tx.Bucket(l.BucketName)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(name, _ []byte) error {
+		partition := bucket.Bucket(name)
+		if partition == nil {
+			return nil
+		}
+		return partition.ForEach(fn)
+	})
+}
+
+// partition returns the partition which contains the session data
+// of the key.
+func (l PartitionedLayout) partition(tx *bolt.Tx, key []byte) *bolt.Bucket {
+	index := tx.Bucket(l.PartitionIndexBucketName)
+	bucket := tx.Bucket(l.BucketName)
+	if index == nil || bucket == nil {
+		return nil
+	}
+	name := index.Get(key)
+	if name == nil {
+		return nil
+	}
+	return bucket.Bucket(name)
+}
+
+// PartitionIndexBucketName returns the name of the partition index bucket
+// which is placed next to the bucket of the given name.
+func PartitionIndexBucketName(bucketName []byte) []byte {
+	return append(append([]byte{}, bucketName...), PartitionIndexBucketSuffix...)
+}
+
+// PartitionName returns the name of the partition which contains sessions
+// of the expiration. It is the big-endian end of the partition's period,
+// so that partitions sort by their expiration. Sessions without
+// an expiration (zero or less) are kept in the last partition, whose
+// period never ends.
+func PartitionName(expiresAt int64, partition time.Duration) []byte {
+	period := int64(partition / time.Second)
+	if period <= 0 {
+		period = 1
+	}
+	end := int64(math.MaxInt64)
+	if expiresAt > 0 {
+		end = (expiresAt + period - 1) / period * period
+	}
+	name := make([]byte, 8)
+	binary.BigEndian.PutUint64(name, uint64(end))
+	return name
+}
+
+// DropExpiredPartitions removes at most limit partitions whose periods end
+// at or before now in Unix time and returns the numbers of removed sessions
+// and partitions. The function is called for each removed session data
+// unless it is nil.
+func DropExpiredPartitions(tx *bolt.Tx, l PartitionedLayout, now int64, limit int, fn func(k, v []byte) error) (int, int, error) {
+	bucket := tx.Bucket(l.BucketName)
+	index := tx.Bucket(l.PartitionIndexBucketName)
+	if bucket == nil || index == nil {
+		return 0, 0, nil
+	}
+
+	// Collect the expired partitions first, because the bucket
+	// must not be modified while iterating over it.
+	var names [][]byte
+	c := bucket.Cursor()
+	for k, v := c.First(); k != nil && len(names) < limit; k, v = c.Next() {
+		if v != nil {
+			continue
+		}
+		if int64(binary.BigEndian.Uint64(k)) > now {
+			break
+		}
+		names = append(names, append([]byte{}, k...))
+	}
+
+	var n int
+	for _, name := range names {
+		err := bucket.Bucket(name).ForEach(func(k, v []byte) error {
+			n++
+			if fn != nil {
+				if err := fn(k, v); err != nil {
+					return err
+				}
+			}
+			// Remove the index entry unless the session has moved
+			// to another partition.
+			if bytes.Equal(index.Get(k), name) {
+				return index.Delete(k)
+			}
+			return nil
+		})
+		if err != nil {
+			return n, 0, err
+		}
+		if err := bucket.DeleteBucket(name); err != nil {
+			return n, 0, err
+		}
+	}
+	return n, len(names), nil
+}
@@ -0,0 +1,197 @@
+package shared
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared/protobuf"
+)
+
+func TestFlatLayout(t *testing.T) {
+	db, closeDB := openTestDB(t)
+	defer closeDB()
+
+	l := FlatLayout{BucketName: []byte("sessions"), ExpiryIndexBucketName: []byte("sessions_expiry")}
+	err := db.Update(func(tx *bolt.Tx) error {
+		if l.Get(tx, []byte("test")) != nil {
+			t.Error("Get() should return nil when the bucket does not exist")
+		}
+		if err := l.Init(tx); err != nil {
+			return err
+		}
+		if err := l.Put(tx, []byte("test"), NewSession([]byte("test"), 60)); err != nil {
+			return err
+		}
+		if l.Get(tx, []byte("test")) == nil {
+			t.Error("Get() should return the session data")
+		}
+		if n := keyN(tx.Bucket(l.ExpiryIndexBucketName)); n != 1 {
+			t.Errorf("the index should have 1 entry (actual: %d)", n)
+		}
+		var n int
+		l.ForEach(tx, func(k, v []byte) error {
+			n++
+			return nil
+		})
+		if n != 1 {
+			t.Errorf("ForEach() should iterate 1 session (actual: %d)", n)
+		}
+		if err := l.Delete(tx, []byte("test")); err != nil {
+			return err
+		}
+		if l.Get(tx, []byte("test")) != nil {
+			t.Error("Get() should return nil after Delete()")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPartitionedLayout(t *testing.T) {
+	db, closeDB := openTestDB(t)
+	defer closeDB()
+
+	l := PartitionedLayout{
+		BucketName:               []byte("sessions"),
+		PartitionIndexBucketName: []byte("sessions_partitions"),
+		Partition:                time.Hour,
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		if l.Get(tx, []byte("test")) != nil {
+			t.Error("Get() should return nil when the bucket does not exist")
+		}
+		if err := l.ForEach(tx, nil); err != nil {
+			return err
+		}
+		if err := l.Init(tx); err != nil {
+			return err
+		}
+
+		// When sessions are put
+		if err := l.Put(tx, []byte("test"), NewSession([]byte("test"), -2*60*60)); err != nil {
+			return err
+		}
+		if err := l.Put(tx, []byte("live"), NewSession([]byte("live"), 2*60*60)); err != nil {
+			return err
+		}
+		if string(l.Get(tx, []byte("test"))) == "" {
+			t.Error("Get() should return the session data")
+		}
+		if n := keyN(tx.Bucket(l.BucketName)); n != 2 {
+			t.Errorf("there should be 2 partitions (actual: %d)", n)
+		}
+
+		// When the session moves to another partition
+		if err := l.Put(tx, []byte("test"), NewSession([]byte("test"), 2*60*60+1)); err != nil {
+			return err
+		}
+		if n := keyN(tx.Bucket(l.PartitionIndexBucketName)); n != 2 {
+			t.Errorf("the index should have 2 entries (actual: %d)", n)
+		}
+		var n int
+		l.ForEach(tx, func(k, v []byte) error {
+			n++
+			return nil
+		})
+		if n != 2 {
+			t.Errorf("ForEach() should iterate 2 sessions (actual: %d)", n)
+		}
+
+		// When the session is deleted
+		if err := l.Delete(tx, []byte("test")); err != nil {
+			return err
+		}
+		if l.Get(tx, []byte("test")) != nil {
+			t.Error("Get() should return nil after Delete()")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPartitionName(t *testing.T) {
+	if end := binary.BigEndian.Uint64(PartitionName(3601, time.Hour)); end != 7200 {
+		t.Errorf("PartitionName() should return %d (actual: %d)", 7200, end)
+	}
+	if end := binary.BigEndian.Uint64(PartitionName(3600, time.Hour)); end != 3600 {
+		t.Errorf("PartitionName() should return %d (actual: %d)", 3600, end)
+	}
+	if end := binary.BigEndian.Uint64(PartitionName(10, 0)); end != 10 {
+		t.Errorf("PartitionName() should return %d (actual: %d)", 10, end)
+	}
+	if end := binary.BigEndian.Uint64(PartitionName(0, time.Hour)); end != math.MaxInt64 {
+		t.Errorf("PartitionName() should return %d (actual: %d)", int64(math.MaxInt64), end)
+	}
+	if end := binary.BigEndian.Uint64(PartitionName(-1, time.Hour)); end != math.MaxInt64 {
+		t.Errorf("PartitionName() should return %d (actual: %d)", int64(math.MaxInt64), end)
+	}
+}
+
+func TestDropExpiredPartitions(t *testing.T) {
+	db, closeDB := openTestDB(t)
+	defer closeDB()
+
+	l := PartitionedLayout{
+		BucketName:               []byte("sessions"),
+		PartitionIndexBucketName: []byte("sessions_partitions"),
+		Partition:                time.Minute,
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		// When the buckets do not exist
+		if n, _, err := DropExpiredPartitions(tx, l, time.Now().Unix(), 10, nil); err != nil || n != 0 {
+			t.Errorf("DropExpiredPartitions() should return 0 (actual: %d, %v)", n, err)
+		}
+		if err := l.Init(tx); err != nil {
+			return err
+		}
+		for i, maxAge := range []int{-3 * 60, -3 * 60, -2 * 60, 60 * 60} {
+			key := []byte{byte(i)}
+			if err := l.Put(tx, key, NewSession([]byte{}, maxAge)); err != nil {
+				return err
+			}
+		}
+		// A session without an expiration
+		var expiresAt int64
+		if err := l.Put(tx, []byte{4}, &protobuf.Session{Values: []byte{}, ExpiresAt: &expiresAt}); err != nil {
+			return err
+		}
+		now := time.Now().Unix()
+
+		// When the limit is reached
+		if n, p, err := DropExpiredPartitions(tx, l, now, 1, nil); err != nil || n != 2 || p != 1 {
+			t.Errorf("DropExpiredPartitions() should return 2, 1 (actual: %d, %d, %v)", n, p, err)
+		}
+		// When the function is given
+		var keys [][]byte
+		fn := func(k, v []byte) error {
+			keys = append(keys, append([]byte{}, k...))
+			return nil
+		}
+		if n, p, err := DropExpiredPartitions(tx, l, now, 10, fn); err != nil || n != 1 || p != 1 {
+			t.Errorf("DropExpiredPartitions() should return 1, 1 (actual: %d, %d, %v)", n, p, err)
+		}
+		if len(keys) != 1 || keys[0][0] != 2 {
+			t.Errorf("the function should be called for the removed session (actual: %v)", keys)
+		}
+		if n := keyN(tx.Bucket(l.BucketName)); n != 2 {
+			t.Errorf("there should be 2 partitions (actual: %d)", n)
+		}
+		if n := keyN(tx.Bucket(l.PartitionIndexBucketName)); n != 2 {
+			t.Errorf("the index should have 2 entries (actual: %d)", n)
+		}
+		if l.Get(tx, []byte{4}) == nil {
+			t.Error("the session without an expiration should remain")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -10,6 +10,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	Session
+	ArchivedSession
 */
 package protobuf
 
@@ -23,9 +24,14 @@ var _ = fmt.Errorf
 var _ = math.Inf
 
 type Session struct {
-	Values           []byte `protobuf:"bytes,1,opt,name=Values" json:"Values,omitempty"`
-	ExpiresAt        *int64 `protobuf:"varint,2,opt,name=ExpiresAt" json:"ExpiresAt,omitempty"`
-	XXX_unrecognized []byte `json:"-"`
+	Values           []byte  `protobuf:"bytes,1,opt,name=Values" json:"Values,omitempty"`
+	ExpiresAt        *int64  `protobuf:"varint,2,opt,name=ExpiresAt" json:"ExpiresAt,omitempty"`
+	CreatedAt        *int64  `protobuf:"varint,3,opt,name=CreatedAt" json:"CreatedAt,omitempty"`
+	UserID           *string `protobuf:"bytes,4,opt,name=UserID" json:"UserID,omitempty"`
+	ParentID         *string `protobuf:"bytes,5,opt,name=ParentID" json:"ParentID,omitempty"`
+	Impersonator     *string `protobuf:"bytes,6,opt,name=Impersonator" json:"Impersonator,omitempty"`
+	ImpersonatedAt   *int64  `protobuf:"varint,7,opt,name=ImpersonatedAt" json:"ImpersonatedAt,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
 }
 
 func (m *Session) Reset()         { *m = Session{} }
@@ -45,3 +51,70 @@ func (m *Session) GetExpiresAt() int64 {
 	}
 	return 0
 }
+
+func (m *Session) GetCreatedAt() int64 {
+	if m != nil && m.CreatedAt != nil {
+		return *m.CreatedAt
+	}
+	return 0
+}
+
+func (m *Session) GetUserID() string {
+	if m != nil && m.UserID != nil {
+		return *m.UserID
+	}
+	return ""
+}
+
+func (m *Session) GetParentID() string {
+	if m != nil && m.ParentID != nil {
+		return *m.ParentID
+	}
+	return ""
+}
+
+func (m *Session) GetImpersonator() string {
+	if m != nil && m.Impersonator != nil {
+		return *m.Impersonator
+	}
+	return ""
+}
+
+func (m *Session) GetImpersonatedAt() int64 {
+	if m != nil && m.ImpersonatedAt != nil {
+		return *m.ImpersonatedAt
+	}
+	return 0
+}
+
+type ArchivedSession struct {
+	Data             []byte  `protobuf:"bytes,1,opt,name=Data" json:"Data,omitempty"`
+	Reason           *string `protobuf:"bytes,2,opt,name=Reason" json:"Reason,omitempty"`
+	ArchivedAt       *int64  `protobuf:"varint,3,opt,name=ArchivedAt" json:"ArchivedAt,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *ArchivedSession) Reset()         { *m = ArchivedSession{} }
+func (m *ArchivedSession) String() string { return proto.CompactTextString(m) }
+func (*ArchivedSession) ProtoMessage()    {}
+
+func (m *ArchivedSession) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *ArchivedSession) GetReason() string {
+	if m != nil && m.Reason != nil {
+		return *m.Reason
+	}
+	return ""
+}
+
+func (m *ArchivedSession) GetArchivedAt() int64 {
+	if m != nil && m.ArchivedAt != nil {
+		return *m.ArchivedAt
+	}
+	return 0
+}
@@ -81,3 +81,84 @@ func TestSession_GetExpiresAt(t *testing.T) {
 	}
 	session.ProtoMessage()
 }
+
+func TestSession_GetCreatedAt(t *testing.T) {
+	// When Session.CreatedAt == nil.
+	session := Session{}
+	if actual := session.GetCreatedAt(); actual != 0 {
+		t.Errorf("session.GetCreatedAt() should return %d (actual: %d)", 0, actual)
+	}
+
+	// When Session.CreatedAt != nil.
+	createdAt := time.Now().Unix()
+	session = Session{CreatedAt: &createdAt}
+	if actual := session.GetCreatedAt(); actual != createdAt {
+		t.Errorf("session.GetCreatedAt() should return %d (actual: %d)", createdAt, actual)
+	}
+}
+
+func TestSession_GetUserID(t *testing.T) {
+	// When Session.UserID == nil.
+	session := Session{}
+	if actual := session.GetUserID(); actual != "" {
+		t.Errorf("session.GetUserID() should return an empty string (actual: %s)", actual)
+	}
+
+	// When Session.UserID != nil.
+	userID := "user"
+	session = Session{UserID: &userID}
+	if actual := session.GetUserID(); actual != userID {
+		t.Errorf("session.GetUserID() should return %s (actual: %s)", userID, actual)
+	}
+}
+
+func TestSession_impersonationGetters(t *testing.T) {
+	// When session == nil.
+	var session *Session
+	if session.GetParentID() != "" || session.GetImpersonator() != "" || session.GetImpersonatedAt() != 0 {
+		t.Error("the getters should return zero values")
+	}
+
+	// When session != nil.
+	parentID, impersonator := "parent", "admin"
+	impersonatedAt := time.Now().Unix()
+	session = &Session{
+		ParentID:       &parentID,
+		Impersonator:   &impersonator,
+		ImpersonatedAt: &impersonatedAt,
+	}
+	if session.GetParentID() != parentID || session.GetImpersonator() != impersonator || session.GetImpersonatedAt() != impersonatedAt {
+		t.Errorf("the getters should return the fields (actual: %+v)", session)
+	}
+}
+
+func TestArchivedSession_Getters(t *testing.T) {
+	// When session == nil.
+	var session *ArchivedSession
+	if session.GetData() != nil || session.GetReason() != "" || session.GetArchivedAt() != 0 {
+		t.Error("the getters should return zero values")
+	}
+
+	// When session != nil.
+	reason := "expired"
+	archivedAt := time.Now().Unix()
+	session = &ArchivedSession{
+		Data:       []byte("test"),
+		Reason:     &reason,
+		ArchivedAt: &archivedAt,
+	}
+	if string(session.GetData()) != "test" {
+		t.Errorf("session.GetData() should return %s (actual: %s)", "test", session.GetData())
+	}
+	if session.GetReason() != reason {
+		t.Errorf("session.GetReason() should return %s (actual: %s)", reason, session.GetReason())
+	}
+	if session.GetArchivedAt() != archivedAt {
+		t.Errorf("session.GetArchivedAt() should return %d (actual: %d)", archivedAt, session.GetArchivedAt())
+	}
+
+	session.Reset()
+	if session.Data != nil || session.Reason != nil || session.ArchivedAt != nil {
+		t.Error("the session should be zero value")
+	}
+}
@@ -0,0 +1,74 @@
+package shared
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"github.com/gogo/protobuf/proto"
+	"github.com/yosssi/boltstore/shared/protobuf"
+)
+
+// ErrCorruptSession is returned when a session data cannot be decoded.
+// The returned errors wrap it and tell the cause.
+var ErrCorruptSession = errors.New("boltstore: corrupt session")
+
+// CorruptSession returns an error which wraps ErrCorruptSession
+// with the cause.
+func CorruptSession(cause error) error {
+	return fmt.Errorf("%w: %v", ErrCorruptSession, cause)
+}
+
+// QuarantineBucketName returns the name of the quarantine bucket
+// which is placed next to the bucket of the given name.
+func QuarantineBucketName(bucketName []byte) []byte {
+	return append(append([]byte{}, bucketName...), QuarantineBucketSuffix...)
+}
+
+// Quarantine moves the session data of the key to the quarantine bucket
+// with the error text and the time. The session data is removed through
+// the layout.
+func Quarantine(tx *bolt.Tx, l Layout, quarantine *bolt.Bucket, key, data []byte, cause error, quarantinedAt int64) error {
+	reason := cause.Error()
+	v, err := proto.Marshal(&protobuf.ArchivedSession{
+		Data:       data,
+		Reason:     &reason,
+		ArchivedAt: &quarantinedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if err := quarantine.Put(key, v); err != nil {
+		return err
+	}
+	return l.Delete(tx, key)
+}
+
+// Restore moves the quarantined session data of the key back through
+// the layout. The session data is left in the quarantine bucket
+// if it or its values still cannot be decoded. The types of the values
+// must be registered to gob beforehand as well as for loading them.
+func Restore(tx *bolt.Tx, l Layout, quarantine *bolt.Bucket, key []byte) error {
+	v := quarantine.Get(key)
+	if v == nil {
+		return fmt.Errorf("boltstore: quarantined session %s does not exist", key)
+	}
+	quarantined, err := ArchivedSession(v)
+	if err != nil {
+		return err
+	}
+	session, err := Session(quarantined.GetData())
+	if err != nil {
+		return CorruptSession(err)
+	}
+	values := make(map[interface{}]interface{})
+	if err := gob.NewDecoder(bytes.NewReader(session.Values)).Decode(&values); err != nil {
+		return CorruptSession(err)
+	}
+	if err := l.Put(tx, key, &session); err != nil {
+		return err
+	}
+	return quarantine.Delete(key)
+}
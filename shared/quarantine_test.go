@@ -0,0 +1,99 @@
+package shared
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestCorruptSession(t *testing.T) {
+	err := CorruptSession(errors.New("test"))
+	if !errors.Is(err, ErrCorruptSession) || err.Error() != "boltstore: corrupt session: test" {
+		t.Errorf("CorruptSession() should return an error which wraps ErrCorruptSession (actual: %v)", err)
+	}
+}
+
+func TestQuarantineBucketName(t *testing.T) {
+	if actual := string(QuarantineBucketName([]byte("sessions"))); actual != "sessions_quarantine" {
+		t.Errorf("QuarantineBucketName() should return %s (actual: %s)", "sessions_quarantine", actual)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	db, closeDB := openTestDB(t)
+	defer closeDB()
+
+	l := FlatLayout{BucketName: []byte("sessions"), ExpiryIndexBucketName: []byte("sessions_expiry")}
+	err := db.Update(func(tx *bolt.Tx) error {
+		if err := l.Init(tx); err != nil {
+			return err
+		}
+		quarantine, err := tx.CreateBucket(QuarantineBucketName(l.BucketName))
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(l.BucketName).Put([]byte("corrupt"), []byte("test")); err != nil {
+			return err
+		}
+		if err := l.Put(tx, []byte("invalidValues"), NewSession([]byte("test"), 60)); err != nil {
+			return err
+		}
+		var values bytes.Buffer
+		if err := gob.NewEncoder(&values).Encode(map[interface{}]interface{}{"foo": "bar"}); err != nil {
+			return err
+		}
+		if err := l.Put(tx, []byte("valid"), NewSession(values.Bytes(), 60)); err != nil {
+			return err
+		}
+
+		for _, key := range []string{"corrupt", "invalidValues", "valid"} {
+			data := append([]byte{}, l.Get(tx, []byte(key))...)
+			if err := Quarantine(tx, l, quarantine, []byte(key), data, errors.New("test"), 100); err != nil {
+				return err
+			}
+		}
+		if n := keyN(tx.Bucket(l.BucketName)); n != 0 {
+			t.Errorf("the sessions should be removed (actual: %d)", n)
+		}
+		session, err := ArchivedSession(quarantine.Get([]byte("corrupt")))
+		if err != nil {
+			return err
+		}
+		if string(session.GetData()) != "test" || session.GetReason() != "test" || session.GetArchivedAt() != 100 {
+			t.Errorf("the quarantined session is invalid (actual: %s)", session.String())
+		}
+
+		// When the session data cannot be decoded
+		if err := Restore(tx, l, quarantine, []byte("corrupt")); !errors.Is(err, ErrCorruptSession) {
+			t.Errorf("Restore() should return an error which wraps ErrCorruptSession (actual: %v)", err)
+		}
+		// When the session values cannot be decoded
+		if err := Restore(tx, l, quarantine, []byte("invalidValues")); !errors.Is(err, ErrCorruptSession) {
+			t.Errorf("Restore() should return an error which wraps ErrCorruptSession (actual: %v)", err)
+		}
+		if quarantine.Get([]byte("invalidValues")) == nil {
+			t.Error("the session should be left in the quarantine bucket")
+		}
+		// When the quarantined session does not exist
+		if err := Restore(tx, l, quarantine, []byte("x")); err == nil {
+			t.Error("Restore() should return an error")
+		}
+		// When the session data can be decoded
+		if err := Restore(tx, l, quarantine, []byte("valid")); err != nil {
+			return err
+		}
+		if l.Get(tx, []byte("valid")) == nil || quarantine.Get([]byte("valid")) != nil {
+			t.Error("the session should be restored")
+		}
+		if n := keyN(tx.Bucket(l.ExpiryIndexBucketName)); n != 1 {
+			t.Errorf("the restored session should be indexed (actual: %d entries)", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
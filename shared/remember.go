@@ -0,0 +1,9 @@
+package shared
+
+// RememberBucketName returns the name of the remember-me token bucket
+// which is placed next to the bucket of the given name. Tokens are stored
+// as session data whose values are the hashes of their validators, so that
+// the reaper removes expired ones.
+func RememberBucketName(bucketName []byte) []byte {
+	return append(append([]byte{}, bucketName...), RememberBucketSuffix...)
+}
@@ -0,0 +1,9 @@
+package shared
+
+import "testing"
+
+func TestRememberBucketName(t *testing.T) {
+	if actual := string(RememberBucketName([]byte("sessions"))); actual != "sessions_remember" {
+		t.Errorf("RememberBucketName() should return %s (actual: %s)", "sessions_remember", actual)
+	}
+}
@@ -0,0 +1,82 @@
+package shared
+
+import (
+	"encoding/binary"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared/protobuf"
+)
+
+// Keys in the meta bucket.
+var (
+	epochKey       = []byte("epoch")
+	userEpochsName = []byte("user_epochs")
+)
+
+// MetaBucketName returns the name of the meta bucket which is placed
+// next to the bucket of the given name.
+func MetaBucketName(bucketName []byte) []byte {
+	return append(append([]byte{}, bucketName...), MetaBucketSuffix...)
+}
+
+// RevocationEpoch returns the revocation epoch in Unix time which applies
+// to the user's sessions. The global epoch is returned if userID is empty.
+// Sessions created before the epoch are revoked. Zero is returned if no
+// session is revoked.
+func RevocationEpoch(meta *bolt.Bucket, userID string) int64 {
+	if meta == nil {
+		return 0
+	}
+	epoch := decodeEpoch(meta.Get(epochKey))
+	if userID == "" {
+		return epoch
+	}
+	if users := meta.Bucket(userEpochsName); users != nil {
+		if userEpoch := decodeEpoch(users.Get([]byte(userID))); userEpoch > epoch {
+			epoch = userEpoch
+		}
+	}
+	return epoch
+}
+
+// SetRevocationEpoch revokes all sessions of the user created before
+// the epoch in Unix time. All sessions are revoked if userID is empty.
+// The epoch never moves backward.
+func SetRevocationEpoch(meta *bolt.Bucket, userID string, epoch int64) error {
+	bucket := meta
+	key := epochKey
+	if userID != "" {
+		var err error
+		if bucket, err = meta.CreateBucketIfNotExists(userEpochsName); err != nil {
+			return err
+		}
+		key = []byte(userID)
+	}
+	if decodeEpoch(bucket.Get(key)) >= epoch {
+		return nil
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(epoch))
+	return bucket.Put(key, b)
+}
+
+// Revoked checks if the session was created before its revocation epoch.
+// An impersonation session is also revoked by the epoch of
+// the impersonator.
+func Revoked(meta *bolt.Bucket, session protobuf.Session) bool {
+	epoch := RevocationEpoch(meta, session.GetUserID())
+	if impersonator := session.GetImpersonator(); impersonator != "" {
+		if e := RevocationEpoch(meta, impersonator); e > epoch {
+			epoch = e
+		}
+	}
+	return epoch > 0 && session.GetCreatedAt() < epoch
+}
+
+// decodeEpoch decodes the epoch stored in the meta bucket.
+func decodeEpoch(b []byte) int64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
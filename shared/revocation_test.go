@@ -0,0 +1,87 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared/protobuf"
+)
+
+func TestMetaBucketName(t *testing.T) {
+	bucketName := []byte("sessions")
+	if actual := string(MetaBucketName(bucketName)); actual != "sessions_meta" {
+		t.Errorf("MetaBucketName() should return %s (actual: %s)", "sessions_meta", actual)
+	}
+	if string(bucketName) != "sessions" {
+		t.Error("MetaBucketName() should not modify the bucket name")
+	}
+}
+
+func TestRevoked(t *testing.T) {
+	db, closeDB := openTestDB(t)
+	defer closeDB()
+
+	createdAt := int64(100)
+	userID := "user"
+	session := protobuf.Session{CreatedAt: &createdAt, UserID: &userID}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		// When the meta bucket does not exist
+		if Revoked(tx.Bucket([]byte("meta")), session) {
+			t.Error("Revoked() should return false (actual: true)")
+		}
+
+		meta, err := tx.CreateBucket([]byte("meta"))
+		if err != nil {
+			return err
+		}
+
+		// When the user's sessions are revoked
+		if err := SetRevocationEpoch(meta, userID, 101); err != nil {
+			return err
+		}
+		if !Revoked(meta, session) {
+			t.Error("Revoked() should return true (actual: false)")
+		}
+		other := protobuf.Session{CreatedAt: &createdAt}
+		if Revoked(meta, other) {
+			t.Error("Revoked() should return false for the other user (actual: true)")
+		}
+
+		// When the sessions of the impersonator are revoked
+		otherUserID := "other"
+		impersonation := protobuf.Session{CreatedAt: &createdAt, UserID: &otherUserID, Impersonator: &userID}
+		if !Revoked(meta, impersonation) {
+			t.Error("Revoked() should return true for the impersonation session (actual: false)")
+		}
+
+		// When all sessions are revoked
+		if err := SetRevocationEpoch(meta, "", 100); err != nil {
+			return err
+		}
+		if Revoked(meta, other) {
+			t.Error("Revoked() should return false for a session created at the epoch (actual: true)")
+		}
+		if err := SetRevocationEpoch(meta, "", 200); err != nil {
+			return err
+		}
+		if !Revoked(meta, other) {
+			t.Error("Revoked() should return true (actual: false)")
+		}
+
+		// When the epoch moves backward
+		if err := SetRevocationEpoch(meta, "", 150); err != nil {
+			return err
+		}
+		if epoch := RevocationEpoch(meta, ""); epoch != 200 {
+			t.Errorf("RevocationEpoch() should return %d (actual: %d)", 200, epoch)
+		}
+		if epoch := RevocationEpoch(meta, userID); epoch != 200 {
+			t.Errorf("RevocationEpoch() should return %d (actual: %d)", 200, epoch)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -1,6 +1,8 @@
 package shared
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
@@ -16,11 +18,31 @@ func Session(data []byte) (protobuf.Session, error) {
 
 // Expired checks if the session is expired.
 func Expired(session protobuf.Session) bool {
-	return *session.ExpiresAt > 0 && *session.ExpiresAt <= time.Now().Unix()
+	return ExpiredAt(session, time.Now(), 0)
+}
+
+// ExpiredAt checks if the session is expired at the given time. The session
+// is regarded as a live one until the grace period for clock skew passes
+// after its expiration.
+func ExpiredAt(session protobuf.Session, now time.Time, skew time.Duration) bool {
+	return *session.ExpiresAt > 0 && *session.ExpiresAt <= now.Add(-skew).Unix()
 }
 
 // NewSession creates and returns a session data.
 func NewSession(values []byte, maxAge int) *protobuf.Session {
-	expiresAt := time.Now().Unix() + int64(maxAge)
-	return &protobuf.Session{Values: values, ExpiresAt: &expiresAt}
+	return NewSessionAt(values, maxAge, time.Now())
+}
+
+// NewSessionAt creates and returns a session data created at the given time.
+func NewSessionAt(values []byte, maxAge int, now time.Time) *protobuf.Session {
+	createdAt := now.Unix()
+	expiresAt := createdAt + int64(maxAge)
+	return &protobuf.Session{Values: values, ExpiresAt: &expiresAt, CreatedAt: &createdAt}
+}
+
+// HashID returns the keyed hash (HMAC-SHA256) of the session ID.
+func HashID(key, id []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(id)
+	return mac.Sum(nil)
 }
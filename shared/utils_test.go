@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"bytes"
 	"github.com/yosssi/boltstore/shared/protobuf"
 	"testing"
 	"time"
@@ -59,3 +60,36 @@ func TestNewSession(t *testing.T) {
 		t.Errorf("NewSession() returned an invalid value (actual: %+v)", session)
 	}
 }
+
+func TestNewSessionAt(t *testing.T) {
+	now := time.Unix(1000, 0)
+	session := NewSessionAt([]byte("test"), 10, now)
+	if session.GetCreatedAt() != 1000 || session.GetExpiresAt() != 1010 {
+		t.Errorf("NewSessionAt() returned an invalid value (actual: %+v)", session)
+	}
+	if ExpiredAt(*session, now.Add(9*time.Second), 0) {
+		t.Error("ExpiredAt() should return false before the expiration")
+	}
+	if !ExpiredAt(*session, now.Add(10*time.Second), 0) {
+		t.Error("ExpiredAt() should return true at the expiration")
+	}
+	if ExpiredAt(*session, now.Add(14*time.Second), 5*time.Second) {
+		t.Error("ExpiredAt() should return false within the grace period")
+	}
+	if !ExpiredAt(*session, now.Add(15*time.Second), 5*time.Second) {
+		t.Error("ExpiredAt() should return true after the grace period")
+	}
+}
+
+func TestHashID(t *testing.T) {
+	hash := HashID([]byte("key"), []byte("id"))
+	if len(hash) != 32 {
+		t.Errorf("HashID() should return 32 bytes (actual: %d)", len(hash))
+	}
+	if !bytes.Equal(hash, HashID([]byte("key"), []byte("id"))) {
+		t.Error("HashID() should return the same value for the same input")
+	}
+	if bytes.Equal(hash, HashID([]byte("other"), []byte("id"))) {
+		t.Error("HashID() should return a different value for a different key")
+	}
+}
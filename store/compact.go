@@ -0,0 +1,50 @@
+package store
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/compact"
+)
+
+// Compact compacts the database file, leaving out expired and revoked
+// sessions, and returns the reopened database with the stats. Sessions are
+// loaded while the live ones are copied to a fresh file, but writes to
+// the store wait until the compaction finishes. All requests wait only
+// while the file is replaced. The database given to New is closed then,
+// so the returned one (or DB) must be used instead of it: other holders
+// of the old database get errors of a closed database, and data which
+// they write during the copy are lost. The database is reopened with
+// Options.BoltOptions. The store keeps using the database given to New
+// if the compaction fails before it is closed, or the reopened original
+// file if replacing the file fails.
+func (s *Store) Compact() (*bolt.DB, compact.Stats, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	// Only Compact replaces the database, so it is read without mu here.
+	tmp, stats, err := compact.CopyFile(s.db, compact.Options{
+		BucketName:               s.config.DBOptions.BucketName,
+		MetaBucketName:           s.config.DBOptions.MetaBucketName,
+		ExpiryIndexBucketName:    s.config.DBOptions.ExpiryIndexBucketName,
+		Partitioned:              s.config.DBOptions.Partition > 0,
+		PartitionIndexBucketName: s.config.DBOptions.PartitionIndexBucketName,
+		Clock:                    s.config.Clock,
+		ClockSkew:                s.config.ClockSkew,
+	})
+	if err != nil {
+		return s.db, stats, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, err := compact.Replace(s.db, tmp, s.config.DBOptions.BoltOptions)
+	if db != nil {
+		s.db = db
+	}
+	return db, stats, err
+}
+
+// DB returns the database which the store uses.
+func (s *Store) DB() *bolt.DB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db
+}
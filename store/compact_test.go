@@ -0,0 +1,89 @@
+package store
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared"
+)
+
+func TestStore_Compact(t *testing.T) {
+	f, err := ioutil.TempFile("", "boltstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	db, err := bolt.Open(f.Name(), 0666, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	str, err := New(db, Config{}, []byte("secret-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	session, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	session.Values["key"] = "value"
+	w := httptest.NewRecorder()
+	if err := str.Save(req, w, session); err != nil {
+		t.Error(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return str.layout.Put(tx, []byte("expired"), shared.NewSession([]byte{}, -1))
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	reopened, stats, err := str.Compact()
+	if err != nil {
+		t.Error(err)
+	}
+	if stats.Copied != 1 || stats.Dropped != 1 {
+		t.Errorf("str.Compact should copy 1 session and drop 1 session (actual: %+v)", stats)
+	}
+	defer reopened.Close()
+	if str.DB() != reopened {
+		t.Error("str.DB should return the reopened database")
+	}
+	if db.Path() != "" {
+		t.Error("the original database should be closed")
+	}
+
+	// The store keeps working with the reopened database.
+	req.Header.Set("Cookie", w.Header().Get("Set-Cookie"))
+	loaded, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	if loaded.IsNew || loaded.Values["key"] != "value" {
+		t.Errorf("the session should be loaded (actual: %+v)", loaded.Values)
+	}
+	err = reopened.View(func(tx *bolt.Tx) error {
+		if str.layout.Get(tx, []byte("expired")) != nil {
+			t.Error("the expired session should be dropped")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// When the database cannot be compacted
+	reopened.Close()
+	if _, _, err := str.Compact(); err == nil {
+		t.Error("str.Compact should return an error")
+	}
+}
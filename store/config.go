@@ -1,6 +1,9 @@
 package store
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/gorilla/sessions"
 	"github.com/yosssi/boltstore/shared"
 )
@@ -11,6 +14,46 @@ type Config struct {
 	SessionOptions sessions.Options
 	// DBOptions represents options for a database.
 	DBOptions Options
+	// Transport represents a way to carry the session ID.
+	// CookieTransport is used if it is nil.
+	Transport Transport
+	// IDGenerator represents a generator of session IDs.
+	// RandomIDGenerator is used if it is nil.
+	IDGenerator IDGenerator
+	// UserIDKey represents the key of the session value which holds
+	// the user ID. The user ID is stored with the session data so that
+	// the user's sessions can be revoked.
+	UserIDKey interface{}
+	// Clock represents the clock which decides the expiration of sessions.
+	// shared.SystemClock is used if it is nil.
+	Clock shared.Clock
+	// ClockSkew represents the grace period after the expiration during
+	// which sessions are still loaded. It tolerates clocks of servers
+	// sharing the database which are slightly apart.
+	ClockSkew time.Duration
+	// InlineMaxSize represents the maximum length of an encoded session
+	// data which is carried by the transport itself, like
+	// sessions.CookieStore does, instead of being stored in the database.
+	// Larger sessions are stored in the database. Sessions move between
+	// the transport and the database when they are saved. Sessions carried
+	// by the transport have no ID. Sessions are always stored in
+	// the database if it is zero.
+	InlineMaxSize int
+	// LockTimeout makes New acquire a lock of the session ID, so that
+	// requests for the same session in the process are serialized. The lock
+	// is released by Save, by Release or when the context of the request
+	// is done, i.e. when the request ends. New returns ErrLockTimeout if
+	// the lock is not acquired within it. Sessions are not locked if it
+	// is zero.
+	LockTimeout time.Duration
+	// Keys represents the typed keys (Key) of session values. Save returns
+	// ErrValueType if a value of their names has another type. New returns
+	// an error if keys of the same name have different types.
+	Keys []ValueKey
+	// SaveErrorHandler handles the error of saving a session in
+	// the middleware. It responds instead of the handler, whose writes
+	// are discarded afterwards. The error is logged if it is nil.
+	SaveErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
 }
 
 // setDefault sets default to the config.
@@ -24,4 +67,34 @@ func (c *Config) setDefault() {
 	if c.DBOptions.BucketName == nil {
 		c.DBOptions.BucketName = []byte(shared.DefaultBucketName)
 	}
+	if c.DBOptions.MetaBucketName == nil {
+		c.DBOptions.MetaBucketName = shared.MetaBucketName(c.DBOptions.BucketName)
+	}
+	if c.DBOptions.ExpiryIndexBucketName == nil {
+		c.DBOptions.ExpiryIndexBucketName = shared.ExpiryIndexBucketName(c.DBOptions.BucketName)
+	}
+	if c.DBOptions.PartitionIndexBucketName == nil {
+		c.DBOptions.PartitionIndexBucketName = shared.PartitionIndexBucketName(c.DBOptions.BucketName)
+	}
+	if c.DBOptions.QuarantineBucketName == nil {
+		c.DBOptions.QuarantineBucketName = shared.QuarantineBucketName(c.DBOptions.BucketName)
+	}
+	if c.Transport == nil {
+		c.Transport = CookieTransport{}
+	}
+	if c.Clock == nil {
+		c.Clock = shared.SystemClock{}
+	}
+	switch g := c.IDGenerator.(type) {
+	case nil:
+		c.IDGenerator = RandomIDGenerator{}
+	case ULIDGenerator:
+		if g.Clock == nil {
+			c.IDGenerator = ULIDGenerator{Clock: c.Clock}
+		}
+	case *ULIDGenerator:
+		if g.Clock == nil {
+			c.IDGenerator = ULIDGenerator{Clock: c.Clock}
+		}
+	}
 }
@@ -18,4 +18,25 @@ func TestConfig_setDefault(t *testing.T) {
 	if string(config.DBOptions.BucketName) != shared.DefaultBucketName {
 		t.Errorf("config.SessionOptions.BucketName should be %+v (actual: %+v)", shared.DefaultBucketName, config.DBOptions.BucketName)
 	}
+	if string(config.DBOptions.MetaBucketName) != shared.DefaultBucketName+shared.MetaBucketSuffix {
+		t.Errorf("config.DBOptions.MetaBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.MetaBucketSuffix, config.DBOptions.MetaBucketName)
+	}
+	if string(config.DBOptions.ExpiryIndexBucketName) != shared.DefaultBucketName+shared.ExpiryIndexBucketSuffix {
+		t.Errorf("config.DBOptions.ExpiryIndexBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.ExpiryIndexBucketSuffix, config.DBOptions.ExpiryIndexBucketName)
+	}
+	if string(config.DBOptions.PartitionIndexBucketName) != shared.DefaultBucketName+shared.PartitionIndexBucketSuffix {
+		t.Errorf("config.DBOptions.PartitionIndexBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.PartitionIndexBucketSuffix, config.DBOptions.PartitionIndexBucketName)
+	}
+	if string(config.DBOptions.QuarantineBucketName) != shared.DefaultBucketName+shared.QuarantineBucketSuffix {
+		t.Errorf("config.DBOptions.QuarantineBucketName should be %s (actual: %s)", shared.DefaultBucketName+shared.QuarantineBucketSuffix, config.DBOptions.QuarantineBucketName)
+	}
+	if _, ok := config.Clock.(shared.SystemClock); !ok {
+		t.Errorf("config.Clock should be shared.SystemClock (actual: %+v)", config.Clock)
+	}
+	if _, ok := config.Transport.(CookieTransport); !ok {
+		t.Errorf("config.Transport should be CookieTransport (actual: %+v)", config.Transport)
+	}
+	if _, ok := config.IDGenerator.(RandomIDGenerator); !ok {
+		t.Errorf("config.IDGenerator should be RandomIDGenerator (actual: %+v)", config.IDGenerator)
+	}
 }
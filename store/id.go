@@ -0,0 +1,103 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/yosssi/boltstore/shared"
+)
+
+// crockford is the Crockford's base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID errors.
+var (
+	errInvalidULID     = errors.New("boltstore: invalid ULID")
+	errRandomGenerator = errors.New("boltstore: random key generation failed")
+)
+
+// IDGenerator represents a generator of session IDs.
+type IDGenerator interface {
+	// GenerateID returns a new session ID.
+	GenerateID() (string, error)
+}
+
+// RandomIDGenerator generates alphanumeric IDs from 32 random bytes.
+type RandomIDGenerator struct{}
+
+// GenerateID returns a base32 encoded random ID.
+func (g RandomIDGenerator) GenerateID() (string, error) {
+	key := securecookie.GenerateRandomKey(32)
+	if key == nil {
+		return "", errRandomGenerator
+	}
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(key), "="), nil
+}
+
+// ULIDGenerator generates ULID-style IDs which consist of a millisecond
+// timestamp and 80 random bits, so that the keys of sessions sort by their
+// creation time in the bucket and a cursor can page them chronologically.
+// IDs generated within the same millisecond are in no particular order.
+// Every ID has fresh random bits, but fewer than RandomIDGenerator's 256,
+// so it should be used only if the order is worth it. The keys are not
+// ordered if Options.IDHashKey is set, because hashes are stored instead.
+type ULIDGenerator struct {
+	// Clock represents the clock of the timestamps. Config.Clock is used
+	// if it is nil.
+	Clock shared.Clock
+}
+
+// GenerateID returns a new ULID.
+func (g ULIDGenerator) GenerateID() (string, error) {
+	clock := g.Clock
+	if clock == nil {
+		clock = shared.SystemClock{}
+	}
+	ms := uint64(clock.Now().UnixNano() / int64(time.Millisecond))
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(ms >> uint(40-8*i))
+	}
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", err
+	}
+	return encodeULID(id), nil
+}
+
+// ULIDTime returns the creation time embedded in the ULID.
+func ULIDTime(id string) (time.Time, error) {
+	if len(id) != 26 {
+		return time.Time{}, errInvalidULID
+	}
+	var ms uint64
+	for i := 0; i < 10; i++ {
+		n := strings.IndexByte(crockford, id[i])
+		if n < 0 {
+			return time.Time{}, errInvalidULID
+		}
+		ms = ms<<5 | uint64(n)
+	}
+	return time.Unix(0, int64(ms)*int64(time.Millisecond)), nil
+}
+
+// encodeULID encodes the 128 bits into 26 characters of Crockford's base32.
+func encodeULID(id [16]byte) string {
+	// The 128 bits are preceded by two zero bits to make 130 bits.
+	var b [26]byte
+	for i := range b {
+		var n byte
+		for j := 0; j < 5; j++ {
+			bit := i*5 + j - 2
+			n <<= 1
+			if bit >= 0 && id[bit/8]&(0x80>>uint(bit%8)) != 0 {
+				n |= 1
+			}
+		}
+		b[i] = crockford[n]
+	}
+	return string(b[:])
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestRandomIDGenerator_GenerateID(t *testing.T) {
+	id, err := RandomIDGenerator{}.GenerateID()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(id) != 52 {
+		t.Errorf("the length of the ID should be 52 (actual: %d)", len(id))
+	}
+}
+
+func TestULIDGenerator_GenerateID(t *testing.T) {
+	clock := &testClock{time.Now()}
+	g := ULIDGenerator{Clock: clock}
+	var prev string
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		if i%10 == 0 {
+			clock.now = clock.now.Add(time.Millisecond)
+		}
+		id, err := g.GenerateID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(id) != 26 {
+			t.Fatalf("the length of the ID should be 26 (actual: %d)", len(id))
+		}
+		if seen[id] {
+			t.Fatalf("the ID should be unique (actual: %s)", id)
+		}
+		seen[id] = true
+		if i%10 == 0 && id <= prev {
+			t.Fatalf("the ID should be greater than the IDs of the previous millisecond (actual: %s <= %s)", id, prev)
+		}
+		prev = id
+	}
+	created, err := ULIDTime(prev)
+	if err != nil {
+		t.Error(err)
+	}
+	if created.UnixNano()/int64(time.Millisecond) != clock.now.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("ULIDTime should return the time of the clock (actual: %s)", created)
+	}
+
+	// When IDs are generated within the same millisecond
+	a, _ := g.GenerateID()
+	b, _ := g.GenerateID()
+	if a[:10] != b[:10] || a[10:] == b[10:] {
+		t.Errorf("the IDs should share the timestamp and have fresh random parts (actual: %s, %s)", a, b)
+	}
+}
+
+func TestULIDTime(t *testing.T) {
+	id := encodeULID([16]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	created, err := ULIDTime(id)
+	if err != nil {
+		t.Error(err)
+	}
+	if ms := created.UnixNano() / int64(time.Millisecond); ms != 0x010203040506 {
+		t.Errorf("ULIDTime should return %d (actual: %d)", 0x010203040506, ms)
+	}
+	if _, err := ULIDTime("short"); err != errInvalidULID {
+		t.Errorf("ULIDTime should return an error %v (actual: %v)", errInvalidULID, err)
+	}
+	if _, err := ULIDTime("UUUUUUUUUUUUUUUUUUUUUUUUUU"); err != errInvalidULID {
+		t.Errorf("ULIDTime should return an error %v (actual: %v)", errInvalidULID, err)
+	}
+}
+
+func TestStore_Save_idGenerator(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	clock := &testClock{time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+	str, err := New(db, Config{IDGenerator: &ULIDGenerator{}, Clock: clock}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	session, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	if err := str.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Error(err)
+	}
+	if created, err := ULIDTime(session.ID); err != nil || !created.Equal(clock.now) {
+		t.Errorf("the session ID should be a ULID of the time of Config.Clock (actual: %s, %s)", session.ID, created)
+	}
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/yosssi/boltstore/shared/protobuf"
+)
+
+// ErrNotImpersonating is returned by StopImpersonation when the session
+// is not an impersonation session.
+var ErrNotImpersonating = errors.New("boltstore: session is not impersonating")
+
+// errNoUserIDKey is returned by Impersonate when Config.UserIDKey is not set.
+var errNoUserIDKey = errors.New("boltstore: Config.UserIDKey is required to impersonate")
+
+// parentIDName is the name used to encode the ID of the parent session.
+const parentIDName = "boltstore-parent"
+
+// Impersonation represents the record of an impersonation session.
+type Impersonation struct {
+	// Impersonator is the user ID of the original session.
+	Impersonator string
+	// UserID is the user ID of the impersonated user.
+	UserID string
+	// ImpersonatedAt is the time when the impersonation started.
+	ImpersonatedAt time.Time
+}
+
+// Impersonate creates a child session of the session which acts as
+// the user, and sends it through the transport instead of the session.
+// The session is stored as it is and restored by StopImpersonation. Who
+// impersonated whom and when is recorded in the session data of the child
+// session. The parent session ID is kept in it encoded with the key pairs,
+// so it is encrypted if they have block keys. Save does nothing for
+// the session after this. Revoking the sessions of the impersonator also
+// revokes the child session. Config.UserIDKey is required.
+func (s *Store) Impersonate(r *http.Request, w http.ResponseWriter, session *sessions.Session, userID string) (*sessions.Session, error) {
+	if s.config.UserIDKey == nil {
+		return nil, errNoUserIDKey
+	}
+
+	// Store the session in the database so that it can be restored.
+	if session.ID == "" {
+		id, err := s.config.IDGenerator.GenerateID()
+		if err != nil {
+			return nil, err
+		}
+		session.ID = id
+	}
+	if err := s.save(session); err != nil {
+		return nil, err
+	}
+	parentID, err := securecookie.EncodeMulti(parentIDName, session.ID, s.codecs...)
+	if err != nil {
+		return nil, err
+	}
+
+	child := sessions.NewSession(s, session.Name())
+	options := *session.Options
+	child.Options = &options
+	child.IsNew = true
+	if child.ID, err = s.config.IDGenerator.GenerateID(); err != nil {
+		return nil, err
+	}
+	child.Values[s.config.UserIDKey] = userID
+	sessionData, err := s.sessionData(child)
+	if err != nil {
+		return nil, err
+	}
+	var impersonator string
+	if v, ok := session.Values[s.config.UserIDKey]; ok {
+		impersonator = fmt.Sprint(v)
+	}
+	now := s.config.Clock.Now().Unix()
+	sessionData.ParentID = &parentID
+	sessionData.Impersonator = &impersonator
+	sessionData.ImpersonatedAt = &now
+	err = s.update(func(tx *bolt.Tx) error {
+		return s.layout.Put(tx, s.key(child.ID), sessionData)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := securecookie.EncodeMulti(child.Name(), child.ID, s.codecs...)
+	if err != nil {
+		return nil, err
+	}
+	s.config.Transport.Set(w, child.Name(), encoded, child.Options)
+	s.replace(session)
+	return child, nil
+}
+
+// StopImpersonation removes the impersonation session, sends the original
+// session through the transport and returns it. If the original session
+// has expired, a new session is returned and the session ID is removed
+// from the transport. Save does nothing for the impersonation session after
+// this. ErrNotImpersonating is returned if the session is not
+// an impersonation session.
+func (s *Store) StopImpersonation(r *http.Request, w http.ResponseWriter, session *sessions.Session) (*sessions.Session, error) {
+	sessionData, ok, err := s.impersonation(session)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotImpersonating
+	}
+	var parentID string
+	if _, err := s.decode(parentIDName, sessionData.GetParentID(), &parentID); err != nil {
+		return nil, err
+	}
+	if err := s.delete(session); err != nil {
+		return nil, err
+	}
+	s.replace(session)
+
+	parent := sessions.NewSession(s, session.Name())
+	options := s.config.SessionOptions
+	parent.Options = &options
+	parent.IsNew = true
+	parent.ID = parentID
+	loaded, err := s.load(parent)
+	if err != nil {
+		return nil, err
+	}
+	if !loaded {
+		// Remove the session ID of the impersonation session.
+		parent.ID = ""
+		removed := options
+		removed.MaxAge = -1
+		s.config.Transport.Set(w, parent.Name(), "", &removed)
+		return parent, nil
+	}
+	parent.IsNew = false
+	encoded, err := securecookie.EncodeMulti(parent.Name(), parent.ID, s.codecs...)
+	if err != nil {
+		return nil, err
+	}
+	s.config.Transport.Set(w, parent.Name(), encoded, parent.Options)
+	return parent, nil
+}
+
+// Impersonating returns the record of the impersonation if the session is
+// an impersonation session.
+func (s *Store) Impersonating(session *sessions.Session) (Impersonation, bool, error) {
+	sessionData, ok, err := s.impersonation(session)
+	if err != nil || !ok {
+		return Impersonation{}, false, err
+	}
+	return Impersonation{
+		Impersonator:   sessionData.GetImpersonator(),
+		UserID:         sessionData.GetUserID(),
+		ImpersonatedAt: time.Unix(sessionData.GetImpersonatedAt(), 0),
+	}, true, nil
+}
+
+// impersonation returns the session data of the session if it is
+// an impersonation session.
+func (s *Store) impersonation(session *sessions.Session) (protobuf.Session, bool, error) {
+	if session.ID == "" {
+		return protobuf.Session{}, false, nil
+	}
+	sessionData, ok, err := s.Lookup(session.ID)
+	if err != nil || !ok || sessionData.ParentID == nil {
+		return protobuf.Session{}, false, err
+	}
+	return sessionData, true, nil
+}
+
+// replace marks the session as replaced by another session in
+// the response, so that Save does not save it, and releases its lock.
+func (s *Store) replace(session *sessions.Session) {
+	session.Values[replacedKey] = true
+	s.Release(session)
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+func TestStore_Impersonate(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	str, err := New(db, Config{UserIDKey: "user"}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	admin, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	admin.Values["user"] = "admin"
+
+	w := httptest.NewRecorder()
+	child, err := str.Impersonate(req, w, admin, "alice")
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok, _ := str.Lookup(admin.ID); !ok {
+		t.Error("the original session should be stored")
+	}
+	impersonation, ok, err := str.Impersonating(child)
+	if err != nil || !ok || impersonation.Impersonator != "admin" || impersonation.UserID != "alice" || impersonation.ImpersonatedAt.IsZero() {
+		t.Errorf("the impersonation should be recorded (actual: %+v, %t, %v)", impersonation, ok, err)
+	}
+	if _, ok, _ := str.Impersonating(admin); ok {
+		t.Error("the original session should not be an impersonation session")
+	}
+
+	// When the impersonation session is loaded and saved
+	req, _ = http.NewRequest("GET", "http://localhost:3000/", nil)
+	req.Header.Set("Cookie", w.Header().Get("Set-Cookie"))
+	child, err = str.New(req, "test")
+	if err != nil || child.IsNew || child.Values["user"] != "alice" {
+		t.Errorf("the impersonation session should be loaded (actual: %+v, %v)", child, err)
+	}
+	child.Values["foo"] = "bar"
+	if err := str.Save(req, httptest.NewRecorder(), child); err != nil {
+		t.Error(err)
+	}
+	if _, ok, _ := str.Impersonating(child); !ok {
+		t.Error("the impersonation should be kept when the session is saved")
+	}
+
+	// When the impersonation stops
+	w = httptest.NewRecorder()
+	parent, err := str.StopImpersonation(req, w, child)
+	if err != nil || parent.IsNew || parent.ID != admin.ID || parent.Values["user"] != "admin" {
+		t.Errorf("the original session should be restored (actual: %+v, %v)", parent, err)
+	}
+	if _, ok, _ := str.Lookup(child.ID); ok {
+		t.Error("the impersonation session should be removed")
+	}
+	req, _ = http.NewRequest("GET", "http://localhost:3000/", nil)
+	req.Header.Set("Cookie", w.Header().Get("Set-Cookie"))
+	if session, err := str.New(req, "test"); err != nil || session.ID != admin.ID {
+		t.Errorf("the original session should be sent (actual: %+v, %v)", session, err)
+	}
+
+	// When the impersonation session is saved after the impersonation stops
+	w = httptest.NewRecorder()
+	if err := str.Save(req, w, child); err != nil {
+		t.Error(err)
+	}
+	if _, ok, _ := str.Lookup(child.ID); ok || len(w.Result().Cookies()) != 0 {
+		t.Errorf("the impersonation session should not be saved (actual: %t, %+v)", ok, w.Result().Cookies())
+	}
+
+	// When the session is not an impersonation session
+	if _, err := str.StopImpersonation(req, httptest.NewRecorder(), parent); err != ErrNotImpersonating {
+		t.Errorf("str.StopImpersonation should return %v (actual: %v)", ErrNotImpersonating, err)
+	}
+
+	// When the original session has expired
+	child, err = str.Impersonate(req, httptest.NewRecorder(), parent, "alice")
+	if err != nil {
+		t.Error(err)
+	}
+	if err := str.delete(parent); err != nil {
+		t.Error(err)
+	}
+	w = httptest.NewRecorder()
+	parent, err = str.StopImpersonation(req, w, child)
+	if err != nil || !parent.IsNew || parent.ID != "" {
+		t.Errorf("a new session should be returned (actual: %+v, %v)", parent, err)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("the cookie should be removed (actual: %+v)", cookies)
+	}
+
+	// When the sessions of the impersonator are revoked
+	revoked := sessions.NewSession(str, "test")
+	revoked.Options = &sessions.Options{MaxAge: 60}
+	revoked.Values["user"] = "revokedAdmin"
+	child, err = str.Impersonate(req, httptest.NewRecorder(), revoked, "alice")
+	if err != nil {
+		t.Error(err)
+	}
+	if err := str.RevokeUser("revokedAdmin", time.Now().Add(time.Second)); err != nil {
+		t.Error(err)
+	}
+	req, _ = http.NewRequest("GET", "http://localhost:3000/", nil)
+	encoded, _ := securecookie.EncodeMulti("test", child.ID, str.codecs...)
+	req.AddCookie(&http.Cookie{Name: "test", Value: encoded})
+	if session, err := str.New(req, "test"); err != nil || !session.IsNew {
+		t.Errorf("the impersonation session should be revoked (actual: %+v, %v)", session, err)
+	}
+
+	// When Config.UserIDKey is not set
+	str, err = New(db, Config{}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := str.Impersonate(req, httptest.NewRecorder(), sessions.NewSession(str, "test"), "alice"); err == nil {
+		t.Error("str.Impersonate should return an error")
+	}
+}
+
+func TestStore_Middleware_impersonate(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	str, err := New(db, Config{UserIDKey: "user"}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	var child *sessions.Session
+	handler := str.Middleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := FromContext(r.Context(), "test")
+		session.Values["user"] = "admin"
+		var err error
+		if child, err = str.Impersonate(r, w, session, "alice"); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// Only the impersonation session should be sent.
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("only one cookie should be set (actual: %+v)", cookies)
+	}
+	req, _ = http.NewRequest("GET", "http://localhost:3000/", nil)
+	req.AddCookie(cookies[0])
+	if session, err := str.New(req, "test"); err != nil || session.ID != child.ID {
+		t.Errorf("the impersonation session should be sent (actual: %+v, %v)", session, err)
+	}
+}
+
+func TestStore_Save_impersonate(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	str, err := New(db, Config{UserIDKey: "user"}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	admin, err := str.Get(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	admin.Values["user"] = "admin"
+	w := httptest.NewRecorder()
+	child, err := str.Impersonate(req, w, admin, "alice")
+	if err != nil {
+		t.Error(err)
+	}
+	if err := sessions.Save(req, w); err != nil {
+		t.Error(err)
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 {
+		t.Errorf("only the impersonation session should be sent (actual: %+v)", cookies)
+	}
+
+	// When the impersonation stops
+	req, _ = http.NewRequest("GET", "http://localhost:3000/", nil)
+	req.AddCookie(w.Result().Cookies()[0])
+	if child, err = str.Get(req, "test"); err != nil {
+		t.Error(err)
+	}
+	w = httptest.NewRecorder()
+	if _, err := str.StopImpersonation(req, w, child); err != nil {
+		t.Error(err)
+	}
+	if err := sessions.Save(req, w); err != nil {
+		t.Error(err)
+	}
+	if _, ok, _ := str.Lookup(child.ID); ok {
+		t.Error("the impersonation session should not be saved again")
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 {
+		t.Errorf("only the original session should be sent (actual: %+v)", cookies)
+	}
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net/http"
+
+	"github.com/boltdb/bolt"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/yosssi/boltstore/shared"
+)
+
+// loadInline decodes the session data carried by the transport. The first
+// return value reports if it was decoded with an old key pair and the
+// second one reports if the session is neither expired nor revoked.
+func (s *Store) loadInline(session *sessions.Session, value string) (bool, bool, error) {
+	var data []byte
+	rotated, err := s.decode(session.Name(), value, &data)
+	if err != nil {
+		return false, false, err
+	}
+	sessionData, err := shared.Session(data)
+	if err != nil {
+		return false, false, shared.CorruptSession(err)
+	}
+	var revoked bool
+	err = s.view(func(tx *bolt.Tx) error {
+		revoked = shared.Revoked(tx.Bucket(s.config.DBOptions.MetaBucketName), sessionData)
+		return nil
+	})
+	if err != nil {
+		return false, false, err
+	}
+	// Check the expiration and the revocation of the session data.
+	if shared.ExpiredAt(sessionData, s.config.Clock.Now(), s.config.ClockSkew) || revoked {
+		return rotated, false, nil
+	}
+	dec := gob.NewDecoder(bytes.NewBuffer(sessionData.Values))
+	if err := dec.Decode(&session.Values); err != nil {
+		session.Values = make(map[interface{}]interface{})
+		return false, false, shared.CorruptSession(err)
+	}
+	if sessionData.CreatedAt != nil {
+		session.Values[createdAtKey] = *sessionData.CreatedAt
+	}
+	return rotated, true, nil
+}
+
+// saveInline sends the session data through the transport if it is not
+// longer than Config.InlineMaxSize when encoded, removing the session data
+// stored in the database. False is returned if it is too long or if it is
+// an impersonation session, which is kept in the database.
+func (s *Store) saveInline(w http.ResponseWriter, session *sessions.Session) (bool, error) {
+	sessionData, err := s.sessionData(session)
+	if err != nil {
+		return false, err
+	}
+	if session.ID != "" {
+		// Keep the creation time of the session data in the database.
+		err := s.view(func(tx *bolt.Tx) error {
+			s.keepMetadata(tx, sessionData, s.key(session.ID))
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+		if sessionData.ParentID != nil {
+			return false, nil
+		}
+	}
+	data, err := proto.Marshal(sessionData)
+	if err != nil {
+		return false, err
+	}
+	encoded, err := securecookie.EncodeMulti(session.Name(), data, s.codecs...)
+	if err != nil || len(shared.InlinePrefix)+len(encoded) > s.config.InlineMaxSize {
+		// Store the session in the database if it is too long to encode.
+		return false, nil
+	}
+	if session.ID != "" {
+		if err := s.delete(session); err != nil {
+			return false, err
+		}
+		session.ID = ""
+	}
+	session.Values[createdAtKey] = sessionData.GetCreatedAt()
+	s.config.Transport.Set(w, session.Name(), shared.InlinePrefix+encoded, session.Options)
+	return true, nil
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared"
+)
+
+// testClock is a clock which shows the given time.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestStore_inline(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	clock := &testClock{time.Now()}
+	str, err := New(db, Config{
+		DBOptions:     Options{BucketName: []byte("inlineTest")},
+		Clock:         clock,
+		InlineMaxSize: 512,
+	}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	count := func() int {
+		var n int
+		db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket([]byte("inlineTest")).Stats().KeyN
+			return nil
+		})
+		return n
+	}
+	save := func(req *http.Request, values map[interface{}]interface{}) (*http.Request, string) {
+		session, err := str.New(req, "test")
+		if err != nil {
+			t.Error(err)
+		}
+		for k, v := range values {
+			if v == nil {
+				delete(session.Values, k)
+			} else {
+				session.Values[k] = v
+			}
+		}
+		w := httptest.NewRecorder()
+		if err := str.Save(req, w, session); err != nil {
+			t.Error(err)
+		}
+		req, _ = http.NewRequest("GET", "http://localhost:3000/", nil)
+		req.Header.Set("Cookie", w.Header().Get("Set-Cookie"))
+		return req, session.ID
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+
+	// When the session is small
+	req, id := save(req, map[interface{}]interface{}{"foo": "bar"})
+	cookie, _ := req.Cookie("test")
+	if id != "" || !strings.HasPrefix(cookie.Value, shared.InlinePrefix) || count() != 0 {
+		t.Errorf("the session should be carried by the cookie (actual: %q, %q, %d)", id, cookie.Value, count())
+	}
+	session, err := str.New(req, "test")
+	if err != nil || session.IsNew || session.Values["foo"] != "bar" {
+		t.Errorf("the session should be loaded from the cookie (actual: %+v, %v)", session, err)
+	}
+	createdAt := session.Values[createdAtKey]
+
+	// When the session grows
+	clock.now = clock.now.Add(time.Minute)
+	req, id = save(req, map[interface{}]interface{}{"large": strings.Repeat("x", 1024)})
+	sessionData, ok, err := str.Lookup(id)
+	if id == "" || !ok || err != nil || count() != 1 {
+		t.Errorf("the session should be stored in the database (actual: %q, %t, %v)", id, ok, err)
+	}
+	if sessionData.GetCreatedAt() != createdAt {
+		t.Errorf("the creation time should be %v (actual: %d)", createdAt, sessionData.GetCreatedAt())
+	}
+	session, err = str.New(req, "test")
+	if err != nil || session.IsNew || session.Values["foo"] != "bar" {
+		t.Errorf("the session should be loaded from the database (actual: %+v, %v)", session, err)
+	}
+
+	// When the session shrinks
+	clock.now = clock.now.Add(time.Minute)
+	req, _ = save(req, map[interface{}]interface{}{"large": nil})
+	if _, ok, _ := str.Lookup(id); ok || count() != 0 {
+		t.Error("the session should be removed from the database")
+	}
+	session, err = str.New(req, "test")
+	if err != nil || session.IsNew || session.Values["foo"] != "bar" || session.Values[createdAtKey] != createdAt {
+		t.Errorf("the session should be loaded from the cookie (actual: %+v, %v)", session, err)
+	}
+
+	// When the session is expired
+	clock.now = clock.now.Add(time.Duration(shared.DefaultMaxAge) * time.Second)
+	session, err = str.New(req, "test")
+	if err != nil || !session.IsNew || len(session.Values) != 0 {
+		t.Errorf("the expired session should not be loaded (actual: %+v, %v)", session, err)
+	}
+
+	// When the cookie is tampered
+	req, _ = http.NewRequest("GET", "http://localhost:3000/", nil)
+	req.AddCookie(&http.Cookie{Name: "test", Value: shared.InlinePrefix + "invalid"})
+	if session, err = str.New(req, "test"); err == nil || !session.IsNew {
+		t.Error("the tampered cookie should not be loaded")
+	}
+}
@@ -0,0 +1,65 @@
+//go:build go1.18
+// +build go1.18
+
+package store
+
+import (
+	"encoding/gob"
+	"reflect"
+
+	"github.com/gorilla/sessions"
+)
+
+// Key represents a key of session values of the type T. Keys are usually
+// created as package-level variables.
+type Key[T any] struct {
+	name string
+	def  T
+}
+
+// NewKey creates and returns a key of the name, registering the type T to
+// gob. Keys given to a store by Config.Keys make Save return ErrValueType
+// if the value of the name has another type.
+func NewKey[T any](name string) Key[T] {
+	if reflect.TypeOf((*T)(nil)).Elem().Kind() != reflect.Interface {
+		var zero T
+		gob.Register(zero)
+	}
+	return Key[T]{name: name}
+}
+
+// WithDefault returns a copy of the key whose Get returns the value if
+// the session has no value of the key.
+func (k Key[T]) WithDefault(value T) Key[T] {
+	k.def = value
+	return k
+}
+
+// Name returns the name of the key, which is the key of session.Values.
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// Type returns the type of the values of the key.
+func (k Key[T]) Type() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Get returns the value of the key in the session. The default value is
+// returned with false if the session has no value of the type.
+func (k Key[T]) Get(session *sessions.Session) (T, bool) {
+	if v, ok := session.Values[k.name].(T); ok {
+		return v, true
+	}
+	return k.def, false
+}
+
+// Set sets the value of the key in the session.
+func (k Key[T]) Set(session *sessions.Session, value T) {
+	session.Values[k.name] = value
+}
+
+// Delete removes the value of the key from the session.
+func (k Key[T]) Delete(session *sessions.Session) {
+	delete(session.Values, k.name)
+}
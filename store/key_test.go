@@ -0,0 +1,114 @@
+//go:build go1.18
+// +build go1.18
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/sessions"
+)
+
+// testUser is a session value of a struct type.
+type testUser struct {
+	ID   int
+	Name string
+}
+
+var (
+	testUserKey  = NewKey[testUser]("keyTest.user")
+	testCountKey = NewKey[int]("keyTest.count").WithDefault(1)
+	testAnyKey   = NewKey[fmt.Stringer]("keyTest.stringer")
+)
+
+func TestKey(t *testing.T) {
+	session := sessions.NewSession(nil, "test")
+
+	if count, ok := testCountKey.Get(session); ok || count != 1 {
+		t.Errorf("testCountKey.Get should return the default value (actual: %d, %t)", count, ok)
+	}
+	if user, ok := testUserKey.Get(session); ok || user != (testUser{}) {
+		t.Errorf("testUserKey.Get should return the zero value (actual: %+v, %t)", user, ok)
+	}
+
+	testCountKey.Set(session, 2)
+	if count, ok := testCountKey.Get(session); !ok || count != 2 {
+		t.Errorf("testCountKey.Get should return %d (actual: %d, %t)", 2, count, ok)
+	}
+	if session.Values[testCountKey.Name()] != 2 {
+		t.Error("the value should be set to session.Values")
+	}
+
+	testCountKey.Delete(session)
+	if _, ok := testCountKey.Get(session); ok {
+		t.Error("the value should be deleted")
+	}
+	if typ := testCountKey.Type(); typ != reflect.TypeOf(0) {
+		t.Errorf("testCountKey.Type should return int (actual: %v)", typ)
+	}
+}
+
+func TestStore_Save_key(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	// When keys of the same name have different types
+	if _, err := New(db, Config{Keys: []ValueKey{testCountKey, NewKey[string](testCountKey.Name())}}, []byte("secret-key")); err == nil {
+		t.Error("New should return an error")
+	}
+
+	str, err := New(db, Config{Keys: []ValueKey{testUserKey, testCountKey, testAnyKey}}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	session, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	testUserKey.Set(session, testUser{ID: 1, Name: "alice"})
+	w := httptest.NewRecorder()
+	if err := str.Save(req, w, session); err != nil {
+		t.Error(err)
+	}
+
+	req.Header.Set("Cookie", w.Header().Get("Set-Cookie"))
+	session, err = str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	if user, ok := testUserKey.Get(session); !ok || user.Name != "alice" {
+		t.Errorf("the value of the struct type should be loaded (actual: %+v, %t)", user, ok)
+	}
+
+	// When the value has another type
+	session.Values[testCountKey.Name()] = "2"
+	if err := str.Save(req, httptest.NewRecorder(), session); !errors.Is(err, ErrValueType) {
+		t.Errorf("str.Save should return %v (actual: %v)", ErrValueType, err)
+	}
+	delete(session.Values, testCountKey.Name())
+
+	// When the value does not implement the interface of the key
+	session.Values[testAnyKey.Name()] = 1
+	if err := str.Save(req, httptest.NewRecorder(), session); !errors.Is(err, ErrValueType) {
+		t.Errorf("str.Save should return %v (actual: %v)", ErrValueType, err)
+	}
+
+	// When the store is not given the key
+	other, err := New(db, Config{}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	if err := other.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Errorf("other.Save should not check the value (actual: %v)", err)
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrValueType is returned by Save when a session value has a type other
+// than the type of its key.
+var ErrValueType = errors.New("boltstore: invalid type of session value")
+
+// ValueKey represents a key of session values of a type. Key implements it.
+type ValueKey interface {
+	// Name returns the key of session.Values.
+	Name() string
+	// Type returns the type of the values.
+	Type() reflect.Type
+}
+
+// keyTypes returns the map of the names of the keys to the types of their
+// values. An error is returned if keys of the same name have different
+// types.
+func keyTypes(keys []ValueKey) (map[string]reflect.Type, error) {
+	types := make(map[string]reflect.Type, len(keys))
+	for _, k := range keys {
+		typ := k.Type()
+		if prev, ok := types[k.Name()]; ok && prev != typ {
+			return nil, fmt.Errorf("boltstore: key %q is of both %v and %v", k.Name(), prev, typ)
+		}
+		types[k.Name()] = typ
+	}
+	return types, nil
+}
+
+// validateValues checks if the session values of the keys of the store
+// have the types of the keys.
+func (s *Store) validateValues(values map[interface{}]interface{}) error {
+	for k, v := range values {
+		name, ok := k.(string)
+		if !ok {
+			continue
+		}
+		typ, ok := s.keyTypes[name]
+		if !ok {
+			continue
+		}
+		if !assignable(v, typ) {
+			return fmt.Errorf("%w: %q should be %v (actual: %T)", ErrValueType, name, typ, v)
+		}
+	}
+	return nil
+}
+
+// assignable checks if the value can be a value of the type.
+func assignable(v interface{}, typ reflect.Type) bool {
+	if v == nil {
+		return typ.Kind() == reflect.Interface
+	}
+	return reflect.TypeOf(v).AssignableTo(typ)
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/yosssi/boltstore/shared"
+)
+
+// ErrLockTimeout is returned by Store.New when the lock of the session
+// is not acquired within Config.LockTimeout.
+var ErrLockTimeout = errors.New("boltstore: session lock timeout")
+
+// locks represents the locks of sessions held in the process.
+type locks struct {
+	mu sync.Mutex
+	m  map[string]*sessionLock
+}
+
+// sessionLock represents the lock of a session.
+type sessionLock struct {
+	// c holds a token while the lock is held.
+	c chan struct{}
+	// n is the number of holders and waiters of the lock.
+	n int
+}
+
+// heldLock represents a lock held by a session.
+type heldLock struct {
+	locks *locks
+	id    string
+	lock  *sessionLock
+	once  sync.Once
+	// released is closed when the lock is released.
+	released chan struct{}
+}
+
+// acquire acquires the lock of the session ID. ErrLockTimeout is returned
+// if it is not acquired within the timeout measured by the clock, and
+// the error of the context if it is done first. The lock is released
+// when the context is done at the latest.
+func (l *locks) acquire(ctx context.Context, id string, timeout time.Duration, clock shared.Clock) (*heldLock, error) {
+	l.mu.Lock()
+	if l.m == nil {
+		l.m = make(map[string]*sessionLock)
+	}
+	lock, ok := l.m[id]
+	if !ok {
+		lock = &sessionLock{c: make(chan struct{}, 1)}
+		l.m[id] = lock
+	}
+	lock.n++
+	l.mu.Unlock()
+
+	select {
+	case lock.c <- struct{}{}:
+		return l.held(ctx, id, lock), nil
+	default:
+	}
+	timer := shared.NewTimer(clock, timeout)
+	defer timer.Stop()
+	select {
+	case lock.c <- struct{}{}:
+		return l.held(ctx, id, lock), nil
+	case <-timer.C():
+		l.done(id, lock)
+		return nil, ErrLockTimeout
+	case <-ctx.Done():
+		l.done(id, lock)
+		return nil, ctx.Err()
+	}
+}
+
+// held returns the acquired lock, which is released when the context
+// is done unless it is released before.
+func (l *locks) held(ctx context.Context, id string, lock *sessionLock) *heldLock {
+	h := &heldLock{locks: l, id: id, lock: lock, released: make(chan struct{})}
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				h.release()
+			case <-h.released:
+			}
+		}()
+	}
+	return h
+}
+
+// release releases the lock only once.
+func (h *heldLock) release() {
+	h.once.Do(func() {
+		close(h.released)
+		<-h.lock.c
+		h.locks.done(h.id, h.lock)
+	})
+}
+
+// done removes the lock when it has no holder and no waiter.
+func (l *locks) done(id string, lock *sessionLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock.n--
+	if lock.n == 0 {
+		delete(l.m, id)
+	}
+}
+
+// Release releases the lock of the session acquired by New without saving
+// the session. Save releases it, and so does the end of the request, when
+// the context of the request is done. It does nothing if the session holds
+// no lock.
+func (s *Store) Release(session *sessions.Session) {
+	if h, ok := session.Values[lockKey].(*heldLock); ok {
+		delete(session.Values, lockKey)
+		h.release()
+	}
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared"
+)
+
+func Test_locks(t *testing.T) {
+	var l locks
+	h, err := l.acquire(context.Background(), "test", 10*time.Millisecond, shared.SystemClock{})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// When the lock is held
+	if _, err := l.acquire(context.Background(), "test", 10*time.Millisecond, shared.SystemClock{}); err != ErrLockTimeout {
+		t.Errorf("l.acquire should return %v (actual: %v)", ErrLockTimeout, err)
+	}
+	if _, err := l.acquire(context.Background(), "other", 10*time.Millisecond, shared.SystemClock{}); err != nil {
+		t.Errorf("the lock of the other ID should be acquired (actual: %v)", err)
+	}
+
+	// When the lock is released while waiting
+	acquired := make(chan *heldLock)
+	go func() {
+		h, _ := l.acquire(context.Background(), "test", time.Second, shared.SystemClock{})
+		acquired <- h
+	}()
+	time.Sleep(10 * time.Millisecond)
+	h.release()
+	if h = <-acquired; h == nil {
+		t.Error("the lock should be acquired after it is released")
+	}
+	h.release()
+	h.release()
+	if _, ok := l.m["test"]; ok {
+		t.Error("the released lock should be removed")
+	}
+
+	// When the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := l.acquire(ctx, "test", time.Second, shared.SystemClock{}); err != nil {
+		t.Error(err)
+	}
+	cancel()
+	if _, err := l.acquire(context.Background(), "test", time.Second, shared.SystemClock{}); err != nil {
+		t.Errorf("the lock should be released when the context is done (actual: %v)", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.acquire(ctx, "test", time.Second, shared.SystemClock{}); err != context.Canceled {
+		t.Errorf("l.acquire should return %v while waiting (actual: %v)", context.Canceled, err)
+	}
+}
+
+func TestStore_lock(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	str, err := New(db, Config{LockTimeout: 20 * time.Millisecond}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	session, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	w := httptest.NewRecorder()
+	if err := str.Save(req, w, session); err != nil {
+		t.Error(err)
+	}
+	cookie := w.Header().Get("Set-Cookie")
+	req.Header.Set("Cookie", cookie)
+	// newRequest returns a request which is not in the registry.
+	newRequest := func() *http.Request {
+		r, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+		r.Header.Set("Cookie", cookie)
+		return r
+	}
+
+	session, err = str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+
+	// When the session is locked
+	other, err := str.New(req, "test")
+	if err != ErrLockTimeout || other.ID != "" {
+		t.Errorf("str.New should return %v and no ID (actual: %v, %q)", ErrLockTimeout, err, other.ID)
+	}
+
+	// When the middleware waits for the lock
+	handler := str.Middleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest())
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("the status code should be %d (actual: %d)", http.StatusServiceUnavailable, w.Code)
+	}
+
+	// When the session is saved
+	session.Values["foo"] = "bar"
+	if err := str.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Error(err)
+	}
+	if _, ok := session.Values[lockKey]; ok {
+		t.Error("the lock should be released")
+	}
+	other, err = str.New(req, "test")
+	if err != nil || other.Values["foo"] != "bar" {
+		t.Errorf("the saved session should be loaded (actual: %+v, %v)", other, err)
+	}
+	str.Release(other)
+	str.Release(other)
+
+	// When the middleware ends the request
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest())
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, newRequest())
+	if w.Code != http.StatusNoContent {
+		t.Errorf("the lock should be released by the middleware (actual: %d)", w.Code)
+	}
+
+	// When the handler ends the request without saving the session
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := str.New(newRequest().WithContext(ctx), "test"); err != nil {
+		t.Error(err)
+	}
+	cancel()
+	if other, err := str.New(newRequest(), "test"); err != nil {
+		t.Errorf("the lock should be released when the request ends (actual: %v)", err)
+	} else {
+		str.Release(other)
+	}
+
+	// When the handler gets the session from the store in the middleware
+	handler = str.Middleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := str.Get(r, "test"); err != nil {
+			t.Errorf("str.Get should not wait for the lock (actual: %v)", err)
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest())
+}
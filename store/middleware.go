@@ -0,0 +1,318 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+
+	"github.com/gorilla/sessions"
+)
+
+// ErrNotSaved is returned by the response writer of the middleware
+// when the session was not saved and Config.SaveErrorHandler responded.
+var ErrNotSaved = errors.New("boltstore: session not saved")
+
+// contextKey represents the key of a session stored in a context.
+type contextKey struct {
+	name string
+}
+
+// NewContext returns a copy of the context which carries the session.
+func NewContext(ctx context.Context, session *sessions.Session) context.Context {
+	return context.WithValue(ctx, contextKey{session.Name()}, session)
+}
+
+// FromContext returns the session of the given name stored in the context.
+func FromContext(ctx context.Context, name string) (*sessions.Session, bool) {
+	session, ok := ctx.Value(contextKey{name}).(*sessions.Session)
+	return session, ok
+}
+
+// Middleware returns a middleware which loads the session of the given name
+// and places it in the request context. The session is saved automatically
+// before the first byte of the response is written if it was modified.
+// The lock of the session (Config.LockTimeout) is released when the request
+// ends, and the middleware responds with 503 Service Unavailable if it is
+// not acquired in time.
+//
+// The session is not added to the registry of gorilla/sessions, which
+// is never cleared without gorilla/context.ClearHandler. Handlers should
+// get the session with FromContext. Store.Get also returns it, but
+// sessions.Save does not save it.
+func (s *Store) Middleware(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, err := s.New(r, name)
+			if err != nil {
+				log.Printf("boltstore: load session error: %v", err)
+			}
+			if errors.Is(err, ErrLockTimeout) {
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			if session == nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			// Release the lock of the session if it is not saved.
+			defer s.Release(session)
+			r = r.WithContext(NewContext(r.Context(), session))
+			sw := newResponseWriter(w, r, s, session)
+			next.ServeHTTP(sw.wrap(), r)
+			// Save the session if the handler wrote nothing.
+			sw.save()
+		})
+	}
+}
+
+// responseWriter wraps an http.ResponseWriter and saves the session
+// before the header is written.
+type responseWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	store   *Store
+	session *sessions.Session
+	// values is a copy of the session values at the time of loading.
+	values map[interface{}]interface{}
+	// maxAge is the session's MaxAge at the time of loading.
+	maxAge int
+	saved  bool
+	// failed reports that the session was not saved and
+	// Config.SaveErrorHandler responded instead of the handler.
+	failed bool
+}
+
+// WriteHeader saves the session and sends the header.
+func (w *responseWriter) WriteHeader(code int) {
+	if w.save(); w.failed {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write saves the session and writes the data.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.save(); w.failed {
+		return 0, ErrNotSaved
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying http.ResponseWriter, so that
+// http.ResponseController reaches it.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush saves the session and flushes the buffered data.
+func (w *responseWriter) Flush() {
+	if w.save(); w.failed {
+		return
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// Hijack saves the session and takes over the connection. The session
+// cookie is not sent since the header is written by the caller.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.save(); w.failed {
+		return nil, nil, ErrNotSaved
+	}
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Push initiates an HTTP/2 server push.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+// ReadFrom saves the session and copies the data from the reader.
+func (w *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if w.save(); w.failed {
+		return 0, ErrNotSaved
+	}
+	return w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+}
+
+// wrap returns the response writer which implements http.Flusher,
+// http.Hijacker, http.Pusher and io.ReaderFrom only if the underlying
+// http.ResponseWriter does, so that type assertions of handlers
+// tell the truth.
+func (w *responseWriter) wrap() http.ResponseWriter {
+	// writer hides the optional methods of responseWriter.
+	type writer interface {
+		http.ResponseWriter
+		Unwrap() http.ResponseWriter
+	}
+	var kind int
+	if _, ok := w.ResponseWriter.(http.Flusher); ok {
+		kind |= 1
+	}
+	if _, ok := w.ResponseWriter.(http.Hijacker); ok {
+		kind |= 2
+	}
+	if _, ok := w.ResponseWriter.(http.Pusher); ok {
+		kind |= 4
+	}
+	if _, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		kind |= 8
+	}
+	switch kind {
+	case 1:
+		return struct {
+			writer
+			http.Flusher
+		}{w, w}
+	case 2:
+		return struct {
+			writer
+			http.Hijacker
+		}{w, w}
+	case 3:
+		return struct {
+			writer
+			http.Flusher
+			http.Hijacker
+		}{w, w, w}
+	case 4:
+		return struct {
+			writer
+			http.Pusher
+		}{w, w}
+	case 5:
+		return struct {
+			writer
+			http.Flusher
+			http.Pusher
+		}{w, w, w}
+	case 6:
+		return struct {
+			writer
+			http.Hijacker
+			http.Pusher
+		}{w, w, w}
+	case 7:
+		return struct {
+			writer
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, w, w, w}
+	case 8:
+		return struct {
+			writer
+			io.ReaderFrom
+		}{w, w}
+	case 9:
+		return struct {
+			writer
+			http.Flusher
+			io.ReaderFrom
+		}{w, w, w}
+	case 10:
+		return struct {
+			writer
+			http.Hijacker
+			io.ReaderFrom
+		}{w, w, w}
+	case 11:
+		return struct {
+			writer
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, w, w, w}
+	case 12:
+		return struct {
+			writer
+			http.Pusher
+			io.ReaderFrom
+		}{w, w, w}
+	case 13:
+		return struct {
+			writer
+			http.Flusher
+			http.Pusher
+			io.ReaderFrom
+		}{w, w, w, w}
+	case 14:
+		return struct {
+			writer
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{w, w, w, w}
+	case 15:
+		return struct {
+			writer
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+			io.ReaderFrom
+		}{w, w, w, w, w}
+	}
+	return struct{ writer }{w}
+}
+
+// save saves the session only once if it was modified. If it fails,
+// Config.SaveErrorHandler responds instead of the handler, or the error
+// is logged if it is nil.
+func (w *responseWriter) save() {
+	if w.saved {
+		return
+	}
+	w.saved = true
+	if !w.modified() {
+		return
+	}
+	err := w.store.Save(w.r, w.ResponseWriter, w.session)
+	if err == nil {
+		return
+	}
+	if h := w.store.config.SaveErrorHandler; h != nil {
+		w.failed = true
+		h(w.ResponseWriter, w.r, err)
+		return
+	}
+	log.Printf("boltstore: save session error: %v", err)
+}
+
+// modified checks if the session was modified after loading. A session
+// whose session ID was decoded with an old key pair is regarded as
+// a modified one, so that the session ID is re-encoded.
+func (w *responseWriter) modified() bool {
+	return Rotated(w.session) || w.session.Options.MaxAge != w.maxAge || !reflect.DeepEqual(w.values, encodableValues(w.session.Values))
+}
+
+// newResponseWriter creates and returns a response writer.
+func newResponseWriter(w http.ResponseWriter, r *http.Request, s *Store, session *sessions.Session) *responseWriter {
+	return &responseWriter{
+		ResponseWriter: w,
+		r:              r,
+		store:          s,
+		session:        session,
+		values:         copyValues(session.Values),
+		maxAge:         session.Options.MaxAge,
+	}
+}
+
+// copyValues returns a deep copy of the session values. Nil is returned
+// if the values cannot be copied, so that they are regarded as modified.
+func copyValues(values map[interface{}]interface{}) map[interface{}]interface{} {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(encodableValues(values)); err != nil {
+		return nil
+	}
+	dst := make(map[interface{}]interface{})
+	if err := gob.NewDecoder(&buf).Decode(&dst); err != nil {
+		return nil
+	}
+	return dst
+}
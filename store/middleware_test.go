@@ -0,0 +1,200 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	gcontext "github.com/gorilla/context"
+	"github.com/gorilla/sessions"
+)
+
+func TestNewContext(t *testing.T) {
+	session := sessions.NewSession(nil, "test")
+	ctx := NewContext(context.Background(), session)
+	actual, ok := FromContext(ctx, "test")
+	if !ok || actual != session {
+		t.Errorf("FromContext should return %+v (actual: %+v)", session, actual)
+	}
+	if _, ok := FromContext(ctx, "other"); ok {
+		t.Error("FromContext should return false for the other name")
+	}
+}
+
+func TestStore_Middleware(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	str, err := New(db, Config{}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	// When the session is modified
+	handler := str.Middleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, ok := FromContext(r.Context(), "test")
+		if !ok {
+			t.Error("the session should be in the request context")
+			return
+		}
+		session.Values["foo"] = "bar"
+		fmt.Fprint(w, "body")
+	}))
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	cookie := w.Header().Get("Set-Cookie")
+	if cookie == "" {
+		t.Error("the session cookie should be set")
+	}
+
+	if len(gcontext.GetAll(req)) != 0 {
+		t.Error("the session should not be added to the registry")
+	}
+
+	// When the handler gets the session from the store
+	handler = str.Middleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := FromContext(r.Context(), "test")
+		if actual, err := str.Get(r, "test"); err != nil || actual != session {
+			t.Errorf("str.Get should return the session in the request context (actual: %+v, %v)", actual, err)
+		}
+	}))
+	req, err = http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// When the session is not modified
+	var loaded interface{}
+	handler = str.Middleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := FromContext(r.Context(), "test")
+		loaded = session.Values["foo"]
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req, err = http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	req.Header.Set("Cookie", cookie)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if loaded != "bar" {
+		t.Errorf("the session value should be %s (actual: %+v)", "bar", loaded)
+	}
+	if w.Header().Get("Set-Cookie") != "" {
+		t.Error("the session cookie should not be set")
+	}
+
+	// When the handler writes nothing
+	handler = str.Middleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := FromContext(r.Context(), "test")
+		session.Options.MaxAge = -1
+	}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Set-Cookie") == "" {
+		t.Error("the session cookie should be deleted")
+	}
+}
+
+// hijackRecorder represents a response recorder which supports
+// http.Hijacker but not http.Flusher.
+type hijackRecorder struct {
+	http.ResponseWriter
+}
+
+func (w hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestStore_Middleware_responseWriter(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	var handled error
+	str, err := New(db, Config{SaveErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+		handled = err
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// When the underlying writer supports http.Flusher only
+	w := httptest.NewRecorder()
+	str.Middleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("the writer should implement http.Flusher")
+		}
+		if _, ok := w.(http.Hijacker); ok {
+			t.Error("the writer should not implement http.Hijacker")
+		}
+		if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); !ok || u.Unwrap() == nil {
+			t.Error("the writer should be unwrapped")
+		}
+	})).ServeHTTP(w, req)
+
+	// When the underlying writer supports http.Hijacker only
+	str.Middleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); ok {
+			t.Error("the writer should not implement http.Flusher")
+		}
+		if _, ok := w.(http.Hijacker); !ok {
+			t.Error("the writer should implement http.Hijacker")
+		}
+	})).ServeHTTP(hijackRecorder{httptest.NewRecorder()}, req)
+
+	// When the session cannot be saved
+	w = httptest.NewRecorder()
+	str.Middleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := FromContext(r.Context(), "test")
+		session.Values["foo"] = make(chan int)
+		if _, err := fmt.Fprint(w, "Hello BoltStore"); err != ErrNotSaved {
+			t.Errorf("Write should return %v (actual: %v)", ErrNotSaved, err)
+		}
+	})).ServeHTTP(w, req)
+	if handled == nil {
+		t.Error("SaveErrorHandler should be called")
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("the status code should be %d (actual: %d)", http.StatusInternalServerError, w.Code)
+	}
+}
+
+func ExampleStore_Middleware() {
+	// db(*bolt.DB) should be opened beforehand and passed by the other function.
+	var db *bolt.DB
+
+	// Create a store.
+	str, err := New(db, Config{}, []byte("secret-key"))
+	if err != nil {
+		panic(err)
+	}
+
+	// Wrap a handler with the middleware. The session is saved
+	// automatically if the handler modifies it.
+	http.Handle("/", str.Middleware("session-key")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := FromContext(r.Context(), "session-key")
+		session.Values["foo"] = "bar"
+		fmt.Fprint(w, "Hello BoltStore")
+	})))
+}
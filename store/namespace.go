@@ -0,0 +1,90 @@
+package store
+
+import (
+	"encoding/gob"
+	"sort"
+
+	"github.com/gorilla/sessions"
+)
+
+func init() {
+	gob.Register(NamespaceKey{})
+}
+
+// NamespaceKey represents the key of a session value in a namespace.
+// It is the key of session.Values, so that values of different namespaces
+// never collide.
+type NamespaceKey struct {
+	Namespace string
+	Key       string
+}
+
+// NamespaceView represents a view of the session values in a namespace.
+// The values are kept in the session, so they are saved with it.
+type NamespaceView struct {
+	session   *sessions.Session
+	namespace string
+}
+
+// Namespace returns a view of the session values in the namespace.
+func Namespace(session *sessions.Session, namespace string) NamespaceView {
+	return NamespaceView{session: session, namespace: namespace}
+}
+
+// Name returns the name of the namespace.
+func (v NamespaceView) Name() string {
+	return v.namespace
+}
+
+// Get returns the value of the key in the namespace.
+func (v NamespaceView) Get(key string) (interface{}, bool) {
+	value, ok := v.session.Values[v.key(key)]
+	return value, ok
+}
+
+// Set sets the value of the key in the namespace.
+func (v NamespaceView) Set(key string, value interface{}) {
+	v.session.Values[v.key(key)] = value
+}
+
+// Delete removes the value of the key from the namespace.
+func (v NamespaceView) Delete(key string) {
+	delete(v.session.Values, v.key(key))
+}
+
+// Clear removes all values of the namespace.
+func (v NamespaceView) Clear() {
+	for k := range v.session.Values {
+		if nk, ok := k.(NamespaceKey); ok && nk.Namespace == v.namespace {
+			delete(v.session.Values, k)
+		}
+	}
+}
+
+// Keys returns the sorted keys of the values in the namespace.
+func (v NamespaceView) Keys() []string {
+	var keys []string
+	for k := range v.session.Values {
+		if nk, ok := k.(NamespaceKey); ok && nk.Namespace == v.namespace {
+			keys = append(keys, nk.Key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Values returns a copy of the values in the namespace.
+func (v NamespaceView) Values() map[string]interface{} {
+	values := make(map[string]interface{})
+	for k, value := range v.session.Values {
+		if nk, ok := k.(NamespaceKey); ok && nk.Namespace == v.namespace {
+			values[nk.Key] = value
+		}
+	}
+	return values
+}
+
+// key returns the key of session.Values of the key in the namespace.
+func (v NamespaceView) key(key string) NamespaceKey {
+	return NamespaceKey{Namespace: v.namespace, Key: key}
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/sessions"
+)
+
+func TestNamespace(t *testing.T) {
+	session := sessions.NewSession(nil, "test")
+	session.Values["user"] = "root"
+	billing := Namespace(session, "billing")
+	auth := Namespace(session, "auth")
+
+	billing.Set("user", "alice")
+	billing.Set("plan", "pro")
+	auth.Set("user", "bob")
+
+	if billing.Name() != "billing" {
+		t.Errorf("billing.Name should return %s (actual: %s)", "billing", billing.Name())
+	}
+	if user, ok := billing.Get("user"); !ok || user != "alice" {
+		t.Errorf("billing.Get should return %s (actual: %v, %t)", "alice", user, ok)
+	}
+	if user, ok := auth.Get("user"); !ok || user != "bob" {
+		t.Errorf("auth.Get should return %s (actual: %v, %t)", "bob", user, ok)
+	}
+	if session.Values["user"] != "root" {
+		t.Error("the value outside the namespaces should not be changed")
+	}
+	if keys := billing.Keys(); !reflect.DeepEqual(keys, []string{"plan", "user"}) {
+		t.Errorf("billing.Keys returned an invalid value (actual: %q)", keys)
+	}
+	if values := auth.Values(); !reflect.DeepEqual(values, map[string]interface{}{"user": "bob"}) {
+		t.Errorf("auth.Values returned an invalid value (actual: %+v)", values)
+	}
+
+	billing.Delete("plan")
+	if _, ok := billing.Get("plan"); ok {
+		t.Error("the value should be deleted")
+	}
+
+	billing.Clear()
+	if len(billing.Keys()) != 0 || len(auth.Keys()) != 1 || len(session.Values) != 2 {
+		t.Errorf("only the namespace should be cleared (actual: %+v)", session.Values)
+	}
+}
+
+func TestNamespace_save(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	str, err := New(db, Config{}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	session, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	Namespace(session, "billing").Set("user", "alice")
+	w := httptest.NewRecorder()
+	if err := str.Save(req, w, session); err != nil {
+		t.Error(err)
+	}
+
+	req.Header.Set("Cookie", w.Header().Get("Set-Cookie"))
+	session, err = str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	if user, ok := Namespace(session, "billing").Get("user"); !ok || user != "alice" {
+		t.Errorf("the value of the namespace should be loaded (actual: %v, %t)", user, ok)
+	}
+}
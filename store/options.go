@@ -1,7 +1,75 @@
 package store
 
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared"
+)
+
 // Options represents options for a database.
 type Options struct {
 	// BucketName represents the name of the bucket which contains sessions.
 	BucketName []byte
+	// MetaBucketName represents the name of the bucket which contains
+	// the revocation epochs. The name of the bucket which contains sessions
+	// suffixed with shared.MetaBucketSuffix is used if it is nil.
+	MetaBucketName []byte
+	// ExpiryIndexBucketName represents the name of the bucket which indexes
+	// sessions by their expiration. The name of the bucket which contains
+	// sessions suffixed with shared.ExpiryIndexBucketSuffix is used if it
+	// is nil. A reaper which seeks the index (reaper.Options.UseExpiryIndex)
+	// does not find revoked sessions; they are removed when they are loaded
+	// or when they expire.
+	ExpiryIndexBucketName []byte
+	// Partition makes the store keep sessions in sub-buckets of the bucket,
+	// each of which contains sessions expiring within this period (e.g.
+	// time.Hour), so that the reaper can drop expired sub-buckets as a
+	// whole. Sessions without an expiration are kept in a sub-bucket which
+	// is never dropped. Revoked sessions are not dropped before their
+	// sub-buckets expire unless they are loaded. Sessions are kept in
+	// the bucket itself if it is zero. The layout of an existing bucket
+	// must not be changed.
+	Partition time.Duration
+	// PartitionIndexBucketName represents the name of the bucket which maps
+	// sessions to their sub-buckets when Partition is set. The name of
+	// the bucket which contains sessions suffixed with
+	// shared.PartitionIndexBucketSuffix is used if it is nil.
+	PartitionIndexBucketName []byte
+	// QuarantineBucketName represents the name of the bucket to which
+	// sessions with invalid session data are moved with their errors.
+	// The name of the bucket which contains sessions suffixed with
+	// shared.QuarantineBucketSuffix is used if it is nil.
+	QuarantineBucketName []byte
+	// IDHashKey represents the key used to hash session IDs. If it is set,
+	// sessions are stored under the keyed hash (HMAC-SHA256) of their IDs
+	// instead of the raw IDs, so that a leaked database file does not
+	// reveal live session IDs.
+	IDHashKey []byte
+	// MigrateUnhashedIDs makes the store move a session stored under its
+	// raw ID to its hashed key when the session is loaded. This is used to
+	// migrate an existing database after setting IDHashKey.
+	MigrateUnhashedIDs bool
+	// KeepExpired makes the store leave expired and revoked sessions in
+	// the database when they are loaded instead of removing them, so that
+	// the reaper can archive them (reaper.Options.Archive).
+	KeepExpired bool
+	// BoltOptions represents options used to reopen the database
+	// after compaction.
+	BoltOptions *bolt.Options
+}
+
+// layout returns the layout of sessions in the database.
+func (o Options) layout() shared.Layout {
+	if o.Partition > 0 {
+		return shared.PartitionedLayout{
+			BucketName:               o.BucketName,
+			PartitionIndexBucketName: o.PartitionIndexBucketName,
+			Partition:                o.Partition,
+		}
+	}
+	return shared.FlatLayout{
+		BucketName:            o.BucketName,
+		ExpiryIndexBucketName: o.ExpiryIndexBucketName,
+	}
 }
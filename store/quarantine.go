@@ -0,0 +1,65 @@
+package store
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared"
+)
+
+// ErrCorruptSession is wrapped by the error which is returned when
+// a session data cannot be decoded. The session data is moved to
+// the quarantine bucket.
+var ErrCorruptSession = shared.ErrCorruptSession
+
+// QuarantinedSession represents a session data which was moved to
+// the quarantine bucket because it could not be decoded.
+type QuarantinedSession struct {
+	// Key is the database key of the session, which is the hashed ID
+	// if IDs are hashed.
+	Key []byte
+	// Data is the session data.
+	Data []byte
+	// Error is the text of the error which occurred in decoding.
+	Error string
+	// QuarantinedAt is the time when the session data was quarantined.
+	QuarantinedAt time.Time
+}
+
+// QuarantinedSessions returns all quarantined session data.
+func (s *Store) QuarantinedSessions() ([]QuarantinedSession, error) {
+	var sessions []QuarantinedSession
+	err := s.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(s.config.DBOptions.QuarantineBucketName)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			quarantined, err := shared.ArchivedSession(v)
+			if err != nil {
+				return err
+			}
+			sessions = append(sessions, QuarantinedSession{
+				Key:           copyBytes(k),
+				Data:          copyBytes(quarantined.GetData()),
+				Error:         quarantined.GetReason(),
+				QuarantinedAt: time.Unix(quarantined.GetArchivedAt(), 0),
+			})
+			return nil
+		})
+	})
+	return sessions, err
+}
+
+// RestoreQuarantined moves the quarantined session data of the database
+// key back to the bucket which contains sessions. An error which wraps
+// ErrCorruptSession is returned if it still cannot be decoded.
+func (s *Store) RestoreQuarantined(key []byte) error {
+	return s.update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(s.config.DBOptions.QuarantineBucketName)
+		if err != nil {
+			return err
+		}
+		return shared.Restore(tx, s.layout, bucket, key)
+	})
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gorilla/securecookie"
+	"github.com/yosssi/boltstore/shared"
+)
+
+func TestStore_quarantine(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	bucketName := []byte(fmt.Sprintf("quarantineTest-%d", time.Now().UnixNano()))
+	str, err := New(db, Config{DBOptions: Options{BucketName: bucketName}}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	// When the protobuf data is invalid
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte("proto"), []byte("test"))
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	encoded, err := securecookie.EncodeMulti("test", "proto", str.codecs...)
+	if err != nil {
+		t.Error(err)
+	}
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	req.AddCookie(&http.Cookie{Name: "test", Value: encoded})
+	session, err := str.New(req, "test")
+	if !errors.Is(err, ErrCorruptSession) || !session.IsNew {
+		t.Errorf("str.New should return a new session and an error which wraps ErrCorruptSession (actual: %v)", err)
+	}
+
+	// When the gob data is invalid
+	err = db.Update(func(tx *bolt.Tx) error {
+		return str.layout.Put(tx, []byte("gob"), shared.NewSession([]byte("test"), 60))
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	session.ID = "gob"
+	if ok, err := str.load(session); ok || !errors.Is(err, ErrCorruptSession) {
+		t.Errorf("str.load should return an error which wraps ErrCorruptSession (actual: %t, %v)", ok, err)
+	}
+
+	quarantined, err := str.QuarantinedSessions()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(quarantined) != 2 || string(quarantined[0].Key) != "gob" || string(quarantined[1].Key) != "proto" {
+		t.Errorf("2 sessions should be quarantined (actual: %+v)", quarantined)
+	}
+	for _, q := range quarantined {
+		if q.Error == "" || q.QuarantinedAt.IsZero() {
+			t.Errorf("the quarantined session should have its error and time (actual: %+v)", q)
+		}
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if str.layout.Get(tx, []byte("proto")) != nil || str.layout.Get(tx, []byte("gob")) != nil {
+			t.Error("the quarantined sessions should be removed from the bucket")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// When the session data still cannot be decoded
+	if err := str.RestoreQuarantined([]byte("proto")); !errors.Is(err, ErrCorruptSession) {
+		t.Errorf("str.RestoreQuarantined should return an error which wraps ErrCorruptSession (actual: %v)", err)
+	}
+
+	// When the session values still cannot be decoded
+	if err := str.RestoreQuarantined([]byte("gob")); !errors.Is(err, ErrCorruptSession) {
+		t.Errorf("str.RestoreQuarantined should return an error which wraps ErrCorruptSession (actual: %v)", err)
+	}
+
+	// When the quarantined session does not exist
+	if err := str.RestoreQuarantined([]byte("x")); err == nil {
+		t.Error("str.RestoreQuarantined should return an error")
+	}
+
+	// When the session data can be decoded
+	var values bytes.Buffer
+	if err := gob.NewEncoder(&values).Encode(map[interface{}]interface{}{"foo": "bar"}); err != nil {
+		t.Error(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		data, err := proto.Marshal(shared.NewSession(values.Bytes(), 60))
+		if err != nil {
+			return err
+		}
+		return shared.Quarantine(tx, str.layout, tx.Bucket(str.config.DBOptions.QuarantineBucketName), []byte("valid"), data, errors.New("test"), 0)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if err := str.RestoreQuarantined([]byte("valid")); err != nil {
+		t.Error(err)
+	}
+	if quarantined, err := str.QuarantinedSessions(); err != nil || len(quarantined) != 2 {
+		t.Errorf("2 sessions should remain quarantined (actual: %+v, %v)", quarantined, err)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if str.layout.Get(tx, []byte("valid")) == nil {
+			t.Error("the restored session should be in the bucket")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
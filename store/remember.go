@@ -0,0 +1,340 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gorilla/sessions"
+	"github.com/yosssi/boltstore/shared"
+)
+
+// ErrTokenTheft is returned by Remember.Login when a remember-me token is
+// used with a validator which has been rotated before the grace period,
+// which means that the token was stolen. All tokens of the user are removed
+// and all sessions of the user are revoked.
+var ErrTokenTheft = errors.New("boltstore: remember-me token theft detected")
+
+// errNoRememberUserIDKey is returned by NewRemember when Config.UserIDKey
+// is not set.
+var errNoRememberUserIDKey = errors.New("boltstore: Config.UserIDKey is required to remember users")
+
+// Sizes of the parts of a remember-me token
+const (
+	selectorSize  = 12
+	validatorSize = 32
+)
+
+// RememberOptions represents options for remember-me tokens.
+type RememberOptions struct {
+	// CookieName represents the name of the cookie which carries tokens.
+	// shared.DefaultRememberCookieName is used if it is empty.
+	CookieName string
+	// MaxAge represents the max age of tokens in seconds.
+	// shared.DefaultRememberMaxAge is used if it is zero.
+	MaxAge int
+	// BucketName represents the name of the bucket which contains tokens.
+	// The name of the bucket which contains sessions suffixed with
+	// shared.RememberBucketSuffix is used if it is nil.
+	BucketName []byte
+	// GracePeriod represents the period after a validator is rotated
+	// during which the previous validator is still accepted, so that
+	// concurrent requests carrying the same token are not regarded as
+	// theft. The token is not rotated again by the previous validator.
+	// shared.DefaultRememberGracePeriod is used if it is zero, and
+	// the previous validator is never accepted if it is negative.
+	GracePeriod time.Duration
+}
+
+// Remember represents remember-me tokens which log users in again after
+// their sessions expire. A token consists of a selector, which is the key
+// of the token in the bucket, and a validator, which is stored hashed and
+// rotated each time the token is used. The values of the token data are
+// the hash of the validator, followed by the hash of the previous validator
+// and the time of the rotation in Unix time once it has been rotated.
+type Remember struct {
+	store   *Store
+	options RememberOptions
+}
+
+// NewRemember creates and returns remember-me tokens of the store.
+// Config.UserIDKey of the store is required.
+func NewRemember(s *Store, options RememberOptions) (*Remember, error) {
+	if s.config.UserIDKey == nil {
+		return nil, errNoRememberUserIDKey
+	}
+	if options.CookieName == "" {
+		options.CookieName = shared.DefaultRememberCookieName
+	}
+	if options.MaxAge == 0 {
+		options.MaxAge = shared.DefaultRememberMaxAge
+	}
+	if options.BucketName == nil {
+		options.BucketName = shared.RememberBucketName(s.config.DBOptions.BucketName)
+	}
+	if options.GracePeriod == 0 {
+		options.GracePeriod = shared.DefaultRememberGracePeriod
+	}
+	err := s.update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(options.BucketName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Remember{store: s, options: options}, nil
+}
+
+// Issue issues a token of the user and sends it in a cookie.
+func (m *Remember) Issue(w http.ResponseWriter, userID string) error {
+	selector, err := randomBytes(selectorSize)
+	if err != nil {
+		return err
+	}
+	validator, data, err := m.newToken(userID, nil, nil)
+	if err != nil {
+		return err
+	}
+	err = m.store.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(m.options.BucketName).Put(selector, data)
+	})
+	if err != nil {
+		return err
+	}
+	m.setCookie(w, encodeToken(selector, validator), m.options.MaxAge)
+	return nil
+}
+
+// Login returns the session of the given name. If the session is new and
+// the request carries a valid token, the session is logged in as the user
+// of the token and saved, and the token is rotated. ErrTokenTheft is
+// returned if the token has been used already.
+func (m *Remember) Login(r *http.Request, w http.ResponseWriter, name string) (*sessions.Session, error) {
+	session, err := m.store.Get(r, name)
+	if err != nil || !session.IsNew {
+		return session, err
+	}
+	userID, ok, err := m.use(r, w)
+	if err != nil || !ok {
+		return session, err
+	}
+	session.Values[m.store.config.UserIDKey] = userID
+	if err := m.store.Save(r, w, session); err != nil {
+		return session, err
+	}
+	return session, nil
+}
+
+// Forget removes the token carried by the request and its cookie.
+func (m *Remember) Forget(r *http.Request, w http.ResponseWriter) error {
+	if selector, _, ok := m.token(r); ok {
+		err := m.store.update(func(tx *bolt.Tx) error {
+			return tx.Bucket(m.options.BucketName).Delete(selector)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	m.setCookie(w, "", -1)
+	return nil
+}
+
+// ForgetUser removes all tokens of the user. It reads all tokens,
+// since they are not indexed by users.
+func (m *Remember) ForgetUser(userID string) error {
+	return m.store.update(func(tx *bolt.Tx) error {
+		return m.deleteUser(tx, userID)
+	})
+}
+
+// use validates the token carried by the request and rotates its
+// validator. The user ID of the token is returned if it is valid.
+func (m *Remember) use(r *http.Request, w http.ResponseWriter) (string, bool, error) {
+	selector, validator, ok := m.token(r)
+	if !ok {
+		return "", false, nil
+	}
+	var userID string
+	var valid, stolen bool
+	var rotated []byte
+	now := m.store.config.Clock.Now()
+	err := m.store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(m.options.BucketName)
+		data := bucket.Get(selector)
+		if data == nil {
+			// The token has been removed.
+			return nil
+		}
+		token, err := shared.Session(data)
+		if err != nil {
+			return nil
+		}
+		if shared.ExpiredAt(token, now, m.store.config.ClockSkew) ||
+			shared.Revoked(tx.Bucket(m.store.config.DBOptions.MetaBucketName), token) {
+			return bucket.Delete(selector)
+		}
+		userID = token.GetUserID()
+		hash := sha256.Sum256(validator)
+		current, previous, rotatedAt := tokenValues(token.Values)
+		switch {
+		case subtle.ConstantTimeCompare(hash[:], current) == 1:
+			// Rotate the validator, keeping the creation time so that
+			// revoking the user's sessions revokes the token too.
+			valid = true
+			if rotated, data, err = m.newToken(userID, token.CreatedAt, current); err != nil {
+				return err
+			}
+			return bucket.Put(selector, data)
+		case previous != nil && subtle.ConstantTimeCompare(hash[:], previous) == 1 &&
+			now.Before(time.Unix(rotatedAt, 0).Add(m.options.GracePeriod)):
+			// The validator has just been rotated by a concurrent request,
+			// whose response sends the current one.
+			valid = true
+			return nil
+		default:
+			// The selector is valid but the validator has been rotated.
+			stolen = true
+			return m.deleteUser(tx, userID)
+		}
+	})
+	if err != nil {
+		return "", false, err
+	}
+	if stolen {
+		m.setCookie(w, "", -1)
+		// Revocation epochs are in seconds and sessions created before
+		// them are revoked, so the sessions created in this second are
+		// revoked by the next one.
+		if err := m.store.RevokeUser(userID, now.Add(time.Second)); err != nil {
+			return "", false, err
+		}
+		return "", false, ErrTokenTheft
+	}
+	if !valid {
+		m.setCookie(w, "", -1)
+		return "", false, nil
+	}
+	if rotated != nil {
+		m.setCookie(w, encodeToken(selector, rotated), m.options.MaxAge)
+	}
+	return userID, true, nil
+}
+
+// newToken returns a new validator and the token data which has its hash.
+// The creation time of the token is kept if it is given. The hash of
+// the previous validator is kept with the time of the rotation if it is
+// given.
+func (m *Remember) newToken(userID string, createdAt *int64, previous []byte) ([]byte, []byte, error) {
+	validator, err := randomBytes(validatorSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := m.store.config.Clock.Now()
+	hash := sha256.Sum256(validator)
+	values := hash[:]
+	if previous != nil {
+		rotatedAt := make([]byte, 8)
+		binary.BigEndian.PutUint64(rotatedAt, uint64(now.Unix()))
+		values = append(append(values, previous...), rotatedAt...)
+	}
+	token := shared.NewSessionAt(values, m.options.MaxAge, now)
+	token.UserID = &userID
+	if createdAt != nil {
+		token.CreatedAt = createdAt
+	}
+	data, err := proto.Marshal(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return validator, data, nil
+}
+
+// tokenValues splits the values of the token data into the hash of
+// the validator, the hash of the previous validator and the time of
+// the rotation. The previous hash is nil if the token has not been rotated.
+func tokenValues(values []byte) ([]byte, []byte, int64) {
+	if len(values) != 2*sha256.Size+8 {
+		return values, nil, 0
+	}
+	return values[:sha256.Size], values[sha256.Size : 2*sha256.Size], int64(binary.BigEndian.Uint64(values[2*sha256.Size:]))
+}
+
+// deleteUser removes all tokens of the user in the transaction.
+// It decodes all tokens in the bucket, so it costs O(n) in the number
+// of tokens of all users. Theft detection calls it as well.
+func (m *Remember) deleteUser(tx *bolt.Tx, userID string) error {
+	bucket := tx.Bucket(m.options.BucketName)
+	var keys [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		if token, err := shared.Session(v); err == nil && token.GetUserID() == userID {
+			keys = append(keys, copyBytes(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// token returns the selector and the validator of the token carried by
+// the request.
+func (m *Remember) token(r *http.Request) ([]byte, []byte, bool) {
+	c, err := r.Cookie(m.options.CookieName)
+	if err != nil {
+		return nil, nil, false
+	}
+	return decodeToken(c.Value)
+}
+
+// setCookie sends the token in a cookie with the session options
+// of the store.
+func (m *Remember) setCookie(w http.ResponseWriter, value string, maxAge int) {
+	options := m.store.config.SessionOptions
+	options.MaxAge = maxAge
+	http.SetCookie(w, sessions.NewCookie(m.options.CookieName, value, &options))
+}
+
+// encodeToken encodes the selector and the validator into a token.
+func encodeToken(selector, validator []byte) string {
+	return base64.RawURLEncoding.EncodeToString(selector) + ":" + base64.RawURLEncoding.EncodeToString(validator)
+}
+
+// decodeToken decodes the token into the selector and the validator.
+func decodeToken(value string) ([]byte, []byte, bool) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return nil, nil, false
+	}
+	selector, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(selector) != selectorSize {
+		return nil, nil, false
+	}
+	validator, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || len(validator) != validatorSize {
+		return nil, nil, false
+	}
+	return selector, validator, true
+}
+
+// randomBytes returns random bytes of the size.
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,195 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared"
+)
+
+func TestRemember(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	// When Config.UserIDKey is not set
+	str, err := New(db, Config{}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	if _, err := NewRemember(str, RememberOptions{}); err == nil {
+		t.Error("NewRemember should return an error")
+	}
+
+	clock := &testClock{time.Now()}
+	bucketName := []byte(fmt.Sprintf("rememberTest-%d", time.Now().UnixNano()))
+	str, err = New(db, Config{
+		DBOptions: Options{BucketName: bucketName},
+		UserIDKey: "user",
+		Clock:     clock,
+	}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	m, err := NewRemember(str, RememberOptions{})
+	if err != nil {
+		t.Error(err)
+	}
+	count := func() int {
+		var n int
+		db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket(shared.RememberBucketName(bucketName)).Stats().KeyN
+			return nil
+		})
+		return n
+	}
+	// login logs in with the remember-me cookie of the response.
+	login := func(w *httptest.ResponseRecorder) (*httptest.ResponseRecorder, map[interface{}]interface{}, error) {
+		req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == shared.DefaultRememberCookieName {
+				req.AddCookie(c)
+			}
+		}
+		next := httptest.NewRecorder()
+		session, err := m.Login(req, next, "test")
+		if session == nil {
+			return next, nil, err
+		}
+		return next, session.Values, err
+	}
+
+	w := httptest.NewRecorder()
+	if err := m.Issue(w, "alice"); err != nil {
+		t.Error(err)
+	}
+	if count() != 1 {
+		t.Errorf("the token should be stored (actual: %d)", count())
+	}
+
+	// When the token is valid
+	next, values, err := login(w)
+	if err != nil || values["user"] != "alice" {
+		t.Errorf("the user should be logged in (actual: %+v, %v)", values, err)
+	}
+	if len(next.Result().Cookies()) != 2 {
+		t.Errorf("the session and the rotated token should be sent (actual: %+v)", next.Result().Cookies())
+	}
+
+	// When the previous validator is used within the grace period
+	if prev, values, err := login(w); err != nil || values["user"] != "alice" || len(prev.Result().Cookies()) != 1 {
+		t.Errorf("the user should be logged in without rotating the token (actual: %+v, %v, %+v)", values, err, prev.Result().Cookies())
+	}
+
+	// When the rotated token is used
+	rotated, values, err := login(next)
+	if err != nil || values["user"] != "alice" {
+		t.Errorf("the user should be logged in with the rotated token (actual: %+v, %v)", values, err)
+	}
+
+	// When the old token is used again
+	if _, _, err := login(w); err != ErrTokenTheft {
+		t.Errorf("m.Login should return %v (actual: %v)", ErrTokenTheft, err)
+	}
+	if count() != 0 {
+		t.Errorf("all tokens of the user should be removed (actual: %d)", count())
+	}
+	db.View(func(tx *bolt.Tx) error {
+		if shared.RevocationEpoch(tx.Bucket(shared.MetaBucketName(bucketName)), "alice") == 0 {
+			t.Error("the sessions of the user should be revoked")
+		}
+		return nil
+	})
+	// The session logged in within the same second is revoked too.
+	req, _ := http.NewRequest("GET", "http://localhost:3000/", nil)
+	for _, c := range rotated.Result().Cookies() {
+		if c.Name == "test" {
+			req.AddCookie(c)
+		}
+	}
+	if session, err := str.New(req, "test"); err != nil || !session.IsNew {
+		t.Errorf("the session of the user should be revoked (actual: %+v, %v)", session, err)
+	}
+
+	// When the token is expired
+	clock.now = clock.now.Add(time.Second)
+	w = httptest.NewRecorder()
+	if err := m.Issue(w, "bob"); err != nil {
+		t.Error(err)
+	}
+	clock.now = clock.now.Add(time.Duration(shared.DefaultRememberMaxAge) * time.Second)
+	if _, values, err := login(w); err != nil || values["user"] != nil {
+		t.Errorf("the user should not be logged in (actual: %+v, %v)", values, err)
+	}
+	if count() != 0 {
+		t.Errorf("the expired token should be removed (actual: %d)", count())
+	}
+
+	// When the previous validator is used after the grace period
+	w = httptest.NewRecorder()
+	if err := m.Issue(w, "dave"); err != nil {
+		t.Error(err)
+	}
+	if _, values, err := login(w); err != nil || values["user"] != "dave" {
+		t.Errorf("the user should be logged in (actual: %+v, %v)", values, err)
+	}
+	clock.now = clock.now.Add(shared.DefaultRememberGracePeriod)
+	if _, _, err := login(w); err != ErrTokenTheft {
+		t.Errorf("m.Login should return %v (actual: %v)", ErrTokenTheft, err)
+	}
+
+	// When the token has been removed
+	w = httptest.NewRecorder()
+	if err := m.Issue(w, "erin"); err != nil {
+		t.Error(err)
+	}
+	if err := m.ForgetUser("erin"); err != nil {
+		t.Error(err)
+	}
+	next, values, err = login(w)
+	if err != nil || values["user"] != nil {
+		t.Errorf("the user should not be logged in (actual: %+v, %v)", values, err)
+	}
+	if cookies := next.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("the cookie should be removed (actual: %+v)", cookies)
+	}
+
+	// When the token is forgotten
+	w = httptest.NewRecorder()
+	if err := m.Issue(w, "bob"); err != nil {
+		t.Error(err)
+	}
+	req, _ = http.NewRequest("GET", "http://localhost:3000/", nil)
+	req.AddCookie(w.Result().Cookies()[0])
+	if err := m.Forget(req, httptest.NewRecorder()); err != nil || count() != 0 {
+		t.Errorf("the token should be removed (actual: %d, %v)", count(), err)
+	}
+
+	// When all tokens of the user are forgotten
+	m.Issue(httptest.NewRecorder(), "bob")
+	m.Issue(httptest.NewRecorder(), "bob")
+	m.Issue(httptest.NewRecorder(), "carol")
+	if err := m.ForgetUser("bob"); err != nil || count() != 1 {
+		t.Errorf("only the tokens of the user should be removed (actual: %d, %v)", count(), err)
+	}
+}
+
+func Test_decodeToken(t *testing.T) {
+	selector, validator := make([]byte, selectorSize), make([]byte, validatorSize)
+	validator[0] = 1
+	s, v, ok := decodeToken(encodeToken(selector, validator))
+	if !ok || len(s) != selectorSize || v[0] != 1 {
+		t.Errorf("decodeToken should return the selector and the validator (actual: %v, %v, %t)", s, v, ok)
+	}
+	for _, value := range []string{"", "invalid", "a:b", encodeToken(validator, selector), "!:!"} {
+		if _, _, ok := decodeToken(value); ok {
+			t.Errorf("decodeToken(%q) should return false", value)
+		}
+	}
+}
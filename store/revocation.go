@@ -0,0 +1,28 @@
+package store
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/yosssi/boltstore/shared"
+)
+
+// RevokeAll revokes all sessions created before the given time.
+// Revoked sessions are treated as expired and removed lazily.
+func (s *Store) RevokeAll(before time.Time) error {
+	return s.revoke("", before)
+}
+
+// RevokeUser revokes all sessions of the user created before the given time,
+// including the impersonation sessions started by the user. The user ID
+// is taken from the session value of Config.UserIDKey.
+func (s *Store) RevokeUser(userID string, before time.Time) error {
+	return s.revoke(userID, before)
+}
+
+// revoke stores the revocation epoch in the meta bucket.
+func (s *Store) revoke(userID string, before time.Time) error {
+	return s.update(func(tx *bolt.Tx) error {
+		return shared.SetRevocationEpoch(tx.Bucket(s.config.DBOptions.MetaBucketName), userID, before.Unix())
+	})
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/sessions"
+)
+
+func TestStore_RevokeAll(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	bucketName := []byte(fmt.Sprintf("revokeAllTest-%d", time.Now().UnixNano()))
+	str, err := New(db, Config{DBOptions: Options{BucketName: bucketName}}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	session := newSavedSession(t, str, nil)
+
+	if err := str.RevokeAll(time.Now().Add(-time.Hour)); err != nil {
+		t.Error(err)
+	}
+	if exists, err := str.load(session); err != nil || !exists {
+		t.Errorf("str.load should return true (actual: %+v, %+v)", exists, err)
+	}
+
+	if err := str.RevokeAll(time.Now().Add(time.Second)); err != nil {
+		t.Error(err)
+	}
+	if exists, err := str.load(session); err != nil || exists {
+		t.Errorf("str.load should return false (actual: %+v, %+v)", exists, err)
+	}
+}
+
+func TestStore_RevokeUser(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	bucketName := []byte(fmt.Sprintf("revokeUserTest-%d", time.Now().UnixNano()))
+	str, err := New(db, Config{DBOptions: Options{BucketName: bucketName}, UserIDKey: "user"}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	alice := newSavedSession(t, str, map[interface{}]interface{}{"user": "alice"})
+	bob := newSavedSession(t, str, map[interface{}]interface{}{"user": "bob"})
+
+	if err := str.RevokeUser("alice", time.Now().Add(time.Second)); err != nil {
+		t.Error(err)
+	}
+	if exists, err := str.load(alice); err != nil || exists {
+		t.Errorf("str.load should return false (actual: %+v, %+v)", exists, err)
+	}
+	if exists, err := str.load(bob); err != nil || !exists {
+		t.Errorf("str.load should return true (actual: %+v, %+v)", exists, err)
+	}
+}
+
+// newSavedSession creates and saves a session which has the values.
+func newSavedSession(t *testing.T, str *Store, values map[interface{}]interface{}) *sessions.Session {
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	session, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	for k, v := range values {
+		session.Values[k] = v
+	}
+	if err := str.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Error(err)
+	}
+	return session
+}
+
+func TestStore_RevokeUser_keepExpired(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	bucketName := []byte(fmt.Sprintf("revokeUserKeepExpiredTest-%d", time.Now().UnixNano()))
+	clock := &testClock{time.Now()}
+	str, err := New(db, Config{
+		DBOptions: Options{BucketName: bucketName, KeepExpired: true},
+		UserIDKey: "user",
+		Clock:     clock,
+	}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	session, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	session.Values["user"] = "alice"
+	w := httptest.NewRecorder()
+	if err := str.Save(req, w, session); err != nil {
+		t.Error(err)
+	}
+	oldID := session.ID
+	req.Header.Set("Cookie", w.HeaderMap.Get("Set-Cookie"))
+
+	if err := str.RevokeUser("alice", clock.now.Add(time.Second)); err != nil {
+		t.Error(err)
+	}
+	clock.now = clock.now.Add(2 * time.Second)
+
+	// Log in again with the cookie of the revoked session.
+	session, err = str.New(req, "test")
+	if err != nil || !session.IsNew {
+		t.Errorf("the revoked session should not be loaded (actual: %+v, %+v)", session, err)
+	}
+	session.Values["user"] = "alice"
+	w = httptest.NewRecorder()
+	if err := str.Save(req, w, session); err != nil {
+		t.Error(err)
+	}
+	if session.ID == oldID {
+		t.Error("the session ID of the revoked session should not be reused")
+	}
+	req.Header.Set("Cookie", w.HeaderMap.Get("Set-Cookie"))
+	session, err = str.New(req, "test")
+	if err != nil || session.IsNew {
+		t.Errorf("the new session should be loaded (actual: %+v, %+v)", session, err)
+	}
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"sync/atomic"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// Rotated checks if the encoded session ID of the session was decoded with
+// a key pair other than the first one. Save re-encodes it with the first
+// key pair, so that old key pairs can be dropped once they are no longer
+// used.
+func Rotated(session *sessions.Session) bool {
+	rotated, _ := session.Values[rotatedKey].(bool)
+	return rotated
+}
+
+// RotatedCookies returns the number of encoded session IDs which have been
+// re-encoded with the first key pair because they were encoded with an old
+// one.
+func (s *Store) RotatedCookies() uint64 {
+	return atomic.LoadUint64(&s.rotated)
+}
+
+// decode decodes the encoded value with the first codec, then with
+// all codecs. True is returned if it was decoded with an old codec.
+func (s *Store) decode(name, value string, dst interface{}) (bool, error) {
+	if len(s.codecs) <= 1 {
+		return false, securecookie.DecodeMulti(name, value, dst, s.codecs...)
+	}
+	if err := securecookie.DecodeMulti(name, value, dst, s.codecs[0]); err == nil {
+		return false, nil
+	}
+	if err := securecookie.DecodeMulti(name, value, dst, s.codecs...); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// rotatedCookie counts the session if its encoded session ID was
+// re-encoded with the first key pair and clears the mark.
+func (s *Store) rotatedCookie(session *sessions.Session) {
+	if Rotated(session) {
+		delete(session.Values, rotatedKey)
+		atomic.AddUint64(&s.rotated, 1)
+	}
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/securecookie"
+)
+
+func TestStore_rotatedCookie(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	oldKey, newKey := []byte("old-secret-key"), []byte("new-secret-key")
+	oldStr, err := New(db, Config{}, oldKey)
+	if err != nil {
+		t.Error(err)
+	}
+	str, err := New(db, Config{}, newKey, nil, oldKey, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Save a session with the old key pair.
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	session, err := oldStr.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	session.Values["foo"] = "bar"
+	w := httptest.NewRecorder()
+	if err := oldStr.Save(req, w, session); err != nil {
+		t.Error(err)
+	}
+	req.Header.Set("Cookie", w.Header().Get("Set-Cookie"))
+
+	// When the cookie is decoded with the old key pair
+	session, err = str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	if session.IsNew || session.Values["foo"] != "bar" || !Rotated(session) {
+		t.Errorf("the session should be loaded and marked as rotated (actual: %+v)", session)
+	}
+	w = httptest.NewRecorder()
+	if err := str.Save(req, w, session); err != nil {
+		t.Error(err)
+	}
+	if Rotated(session) || str.RotatedCookies() != 1 {
+		t.Errorf("the rotated cookie should be counted (actual: %t, %d)", Rotated(session), str.RotatedCookies())
+	}
+	cookie := w.Result().Cookies()[0]
+	var id string
+	if err := securecookie.DecodeMulti("test", cookie.Value, &id, securecookie.CodecsFromPairs(newKey)...); err != nil || id != session.ID {
+		t.Errorf("the cookie should be encoded with the new key pair (actual: %q, %v)", id, err)
+	}
+
+	// When the cookie is decoded with the new key pair
+	req.Header.Set("Cookie", w.Header().Get("Set-Cookie"))
+	session, err = str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	if session.IsNew || Rotated(session) {
+		t.Errorf("the session should not be marked as rotated (actual: %+v)", session)
+	}
+	if _, ok := session.Values[rotatedKey]; ok {
+		t.Error("the mark should not be stored")
+	}
+}
+
+func TestStore_Middleware_rotatedCookie(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	oldKey := []byte("old-secret-key")
+	oldStr, err := New(db, Config{}, oldKey)
+	if err != nil {
+		t.Error(err)
+	}
+	str, err := New(db, Config{}, []byte("new-secret-key"), nil, oldKey, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	session, err := oldStr.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	w := httptest.NewRecorder()
+	if err := oldStr.Save(req, w, session); err != nil {
+		t.Error(err)
+	}
+	req.Header.Set("Cookie", w.Header().Get("Set-Cookie"))
+
+	// When the handler does not modify the session
+	handler := str.Middleware("test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Header().Get("Set-Cookie") == "" || str.RotatedCookies() != 1 {
+		t.Errorf("the cookie should be re-encoded (actual: %d)", str.RotatedCookies())
+	}
+}
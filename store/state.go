@@ -0,0 +1,40 @@
+package store
+
+// stateKey represents the key of a state of a session kept in its values.
+// It is unexported, so that it never collides with the keys of
+// applications, and it is removed from the values before they are encoded.
+type stateKey int
+
+const (
+	// rotatedKey marks a session whose ID was decoded with an old key pair.
+	rotatedKey stateKey = iota
+	// createdAtKey keeps the creation time of a session carried
+	// by the transport.
+	createdAtKey
+	// lockKey keeps the lock held by a session.
+	lockKey
+	// replacedKey marks a session replaced by another session
+	// in the response.
+	replacedKey
+)
+
+// encodableValues returns the session values without the states
+// of the session.
+func encodableValues(values map[interface{}]interface{}) map[interface{}]interface{} {
+	var n int
+	for _, k := range []stateKey{rotatedKey, createdAtKey, lockKey, replacedKey} {
+		if _, ok := values[k]; ok {
+			n++
+		}
+	}
+	if n == 0 {
+		return values
+	}
+	dst := make(map[interface{}]interface{}, len(values))
+	for k, v := range values {
+		if _, ok := k.(stateKey); !ok {
+			dst[k] = v
+		}
+	}
+	return dst
+}
@@ -0,0 +1,18 @@
+package store
+
+import "testing"
+
+func Test_encodableValues(t *testing.T) {
+	values := map[interface{}]interface{}{"foo": "bar"}
+	if actual := encodableValues(values); len(actual) != 1 {
+		t.Errorf("encodableValues should return the values (actual: %+v)", actual)
+	}
+	values[rotatedKey] = true
+	values[createdAtKey] = int64(1)
+	if actual := encodableValues(values); len(actual) != 1 || actual["foo"] != "bar" {
+		t.Errorf("encodableValues should remove the states (actual: %+v)", actual)
+	}
+	if len(values) != 3 {
+		t.Error("encodableValues should not modify the values")
+	}
+}
@@ -2,29 +2,47 @@ package store
 
 import (
 	"bytes"
-	"encoding/base32"
 	"encoding/gob"
-	"github.com/gogo/protobuf/proto"
+	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/boltdb/bolt"
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
 	"github.com/yosssi/boltstore/shared"
+	"github.com/yosssi/boltstore/shared/protobuf"
 )
 
 // Store represents a session store.
 type Store struct {
-	codecs []securecookie.Codec
-	config Config
-	db     *bolt.DB
+	// rotated is the number of re-encoded session IDs. It is accessed
+	// atomically, so it is kept 64-bit aligned at the top of the struct.
+	rotated uint64
+	codecs  []securecookie.Codec
+	config  Config
+	layout  shared.Layout
+	locks   locks
+	// keyTypes maps the names of Config.Keys to the types of their values.
+	keyTypes map[string]reflect.Type
+	// mu guards db, which is replaced by compaction.
+	mu sync.RWMutex
+	// wmu makes writes wait while compaction copies the database.
+	wmu sync.RWMutex
+	db  *bolt.DB
 }
 
 // Get returns a session for the given name after adding it to the registry.
+// The session loaded by the middleware of the store is returned instead
+// if the request context carries it.
 //
 // See gorilla/sessions FilesystemStore.Get().
 func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	if session, ok := FromContext(r.Context(), name); ok && session.Store() == s {
+		return session, nil
+	}
 	return sessions.GetRegistry(r).Get(s, name)
 }
 
@@ -34,27 +52,70 @@ func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
 func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
 	var err error
 	session := sessions.NewSession(s, name)
-	session.Options = &s.config.SessionOptions
+	// Copy the options so that changing them affects only this session.
+	options := s.config.SessionOptions
+	session.Options = &options
 	session.IsNew = true
-	if c, errCookie := r.Cookie(name); errCookie == nil {
-		err = securecookie.DecodeMulti(name, c.Value, &session.ID, s.codecs...)
-		if err == nil {
-			ok, err := s.load(session)
-			session.IsNew = !(err == nil && ok) // not new if no error and data available
+	if value, ok := s.config.Transport.Get(r, name); ok {
+		var rotated, loaded bool
+		if inline := strings.TrimPrefix(value, shared.InlinePrefix); inline != value {
+			rotated, loaded, err = s.loadInline(session, inline)
+		} else if rotated, err = s.decode(name, value, &session.ID); err == nil {
+			var h *heldLock
+			if s.config.LockTimeout > 0 {
+				if h, err = s.locks.acquire(r.Context(), session.ID, s.config.LockTimeout, s.config.Clock); err != nil {
+					// Drop the ID so that saving the session does not
+					// overwrite the locked one.
+					session.ID = ""
+					return session, err
+				}
+			}
+			loaded, err = s.load(session)
+			if h != nil {
+				session.Values[lockKey] = h
+			}
+		}
+		session.IsNew = !(err == nil && loaded) // not new if no error and data available
+		if err == nil && rotated {
+			// Mark the session so that Save re-encodes the session ID
+			// with the first key pair.
+			session.Values[rotatedKey] = true
 		}
 	}
 	return session, err
 }
 
-// Save adds a single session to the response.
+// Save adds a single session to the response through the transport.
+// It does nothing for a session replaced by Impersonate or
+// StopImpersonation, whose replacement has already been sent.
 func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	defer s.Release(session)
+	if _, replaced := session.Values[replacedKey]; replaced {
+		return nil
+	}
 	if session.Options.MaxAge < 0 {
-		s.delete(session)
-		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		if session.ID != "" {
+			s.delete(session)
+		}
+		s.config.Transport.Set(w, session.Name(), "", session.Options)
+		delete(session.Values, rotatedKey)
 	} else {
-		// Build an alphanumeric ID.
+		if s.config.InlineMaxSize > 0 {
+			ok, err := s.saveInline(w, session)
+			if err != nil {
+				return err
+			}
+			if ok {
+				s.rotatedCookie(session)
+				return nil
+			}
+		}
 		if session.ID == "" {
-			session.ID = strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+			id, err := s.config.IDGenerator.GenerateID()
+			if err != nil {
+				return err
+			}
+			session.ID = id
 		}
 		if err := s.save(session); err != nil {
 			return err
@@ -63,21 +124,120 @@ func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.S
 		if err != nil {
 			return err
 		}
-		http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+		s.config.Transport.Set(w, session.Name(), encoded, session.Options)
+		s.rotatedCookie(session)
 	}
 	return nil
 }
 
+// Lookup returns the session data stored for the session ID as it is,
+// even if it is expired. False is returned if there is no such session data.
+func (s *Store) Lookup(id string) (protobuf.Session, bool, error) {
+	var sessionData protobuf.Session
+	var ok bool
+	err := s.view(func(tx *bolt.Tx) error {
+		data := s.layout.Get(tx, s.key(id))
+		if data == nil {
+			return nil
+		}
+		ok = true
+		var err error
+		// Copy the session data, because it is not safe
+		// outside of this transaction.
+		sessionData, err = shared.Session(copyBytes(data))
+		if err != nil {
+			return shared.CorruptSession(err)
+		}
+		return nil
+	})
+	return sessionData, ok, err
+}
+
 // load loads a session data from the database.
 // True is returned if there is a session data in the database.
 func (s *Store) load(session *sessions.Session) (bool, error) {
-	// exists represents whether a session data exists or not.
-	var exists bool
-	err := s.db.View(func(tx *bolt.Tx) error {
-		id := []byte(session.ID)
-		bucket := tx.Bucket(s.config.DBOptions.BucketName)
-		// Get the session data.
-		data := bucket.Get(id)
+	key := s.key(session.ID)
+	var data []byte
+	var sessionData protobuf.Session
+	var revoked bool
+	var decodeErr error
+	err := s.view(func(tx *bolt.Tx) error {
+		// Copy the session data, because it is not safe
+		// outside of this transaction.
+		data = copyBytes(s.layout.Get(tx, key))
+		if data == nil {
+			return nil
+		}
+		if sessionData, decodeErr = shared.Session(data); decodeErr != nil {
+			return nil
+		}
+		revoked = shared.Revoked(tx.Bucket(s.config.DBOptions.MetaBucketName), sessionData)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if decodeErr != nil {
+		return false, s.quarantine(key, data, decodeErr)
+	}
+	if data == nil && s.config.DBOptions.IDHashKey != nil && s.config.DBOptions.MigrateUnhashedIDs {
+		if data, err = s.migrate(session.ID, key); err != nil {
+			return false, err
+		}
+		if data != nil {
+			// Load the migrated session data again.
+			return s.load(session)
+		}
+	}
+	if data == nil {
+		return false, nil
+	}
+	// Check the expiration and the revocation of the session data.
+	if shared.ExpiredAt(sessionData, s.config.Clock.Now(), s.config.ClockSkew) || revoked {
+		// Drop the ID so that saving the session does not reuse the record
+		// and its metadata, which would revoke the new session again.
+		session.ID = ""
+		if s.config.DBOptions.KeepExpired {
+			return false, nil
+		}
+		return false, s.update(func(tx *bolt.Tx) error {
+			return s.layout.Delete(tx, key)
+		})
+	}
+	dec := gob.NewDecoder(bytes.NewBuffer(sessionData.Values))
+	if err := dec.Decode(&session.Values); err != nil {
+		session.Values = make(map[interface{}]interface{})
+		return false, s.quarantine(key, data, err)
+	}
+	return true, nil
+}
+
+// quarantine moves the session data of the key to the quarantine bucket
+// unless it has been changed, and returns an error which wraps
+// ErrCorruptSession with the cause.
+func (s *Store) quarantine(key, data []byte, cause error) error {
+	err := s.update(func(tx *bolt.Tx) error {
+		if !bytes.Equal(s.layout.Get(tx, key), data) {
+			return nil
+		}
+		bucket, err := tx.CreateBucketIfNotExists(s.config.DBOptions.QuarantineBucketName)
+		if err != nil {
+			return err
+		}
+		return shared.Quarantine(tx, s.layout, bucket, key, data, cause, s.config.Clock.Now().Unix())
+	})
+	if err != nil {
+		return err
+	}
+	return shared.CorruptSession(cause)
+}
+
+// migrate moves the session data stored under the raw ID to the hashed key
+// and returns the data. Nil is returned if there is no such session data.
+func (s *Store) migrate(id string, key []byte) ([]byte, error) {
+	var data []byte
+	err := s.update(func(tx *bolt.Tx) error {
+		data = copyBytes(s.layout.Get(tx, []byte(id)))
 		if data == nil {
 			return nil
 		}
@@ -85,24 +245,18 @@ func (s *Store) load(session *sessions.Session) (bool, error) {
 		if err != nil {
 			return err
 		}
-		// Check the expiration of the session data.
-		if shared.Expired(sessionData) {
-			err := s.db.Update(func(txu *bolt.Tx) error {
-				return txu.Bucket(s.config.DBOptions.BucketName).Delete(id)
-			})
+		if err := s.layout.Delete(tx, []byte(id)); err != nil {
 			return err
 		}
-		exists = true
-		dec := gob.NewDecoder(bytes.NewBuffer(sessionData.Values))
-		return dec.Decode(&session.Values)
+		return s.layout.Put(tx, key, &sessionData)
 	})
-	return exists, err
+	return data, err
 }
 
 // delete removes the key-value from the database.
 func (s *Store) delete(session *sessions.Session) error {
-	err := s.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(s.config.DBOptions.BucketName).Delete([]byte(session.ID))
+	err := s.update(func(tx *bolt.Tx) error {
+		return s.layout.Delete(tx, s.key(session.ID))
 	})
 	if err != nil {
 		return err
@@ -110,34 +264,121 @@ func (s *Store) delete(session *sessions.Session) error {
 	return nil
 }
 
-// save stores the session data in the database.
-func (s *Store) save(session *sessions.Session) error {
+// sessionData returns the session data of the session.
+func (s *Store) sessionData(session *sessions.Session) (*protobuf.Session, error) {
+	if err := s.validateValues(session.Values); err != nil {
+		return nil, err
+	}
 	var buf bytes.Buffer
 	enc := gob.NewEncoder(&buf)
-	err := enc.Encode(session.Values)
+	err := enc.Encode(encodableValues(session.Values))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	sessionData := shared.NewSessionAt(buf.Bytes(), session.Options.MaxAge, s.config.Clock.Now())
+	if userID, ok := session.Values[s.config.UserIDKey]; ok && s.config.UserIDKey != nil {
+		id := fmt.Sprint(userID)
+		sessionData.UserID = &id
 	}
-	data, err := proto.Marshal(shared.NewSession(buf.Bytes(), session.Options.MaxAge))
+	// Keep the creation time of the session carried by the transport.
+	if createdAt, ok := session.Values[createdAtKey].(int64); ok {
+		sessionData.CreatedAt = &createdAt
+	}
+	return sessionData, nil
+}
+
+// save stores the session data in the database.
+func (s *Store) save(session *sessions.Session) error {
+	sessionData, err := s.sessionData(session)
 	if err != nil {
 		return err
 	}
-	err = s.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(s.config.DBOptions.BucketName).Put([]byte(session.ID), data)
+	err = s.update(func(tx *bolt.Tx) error {
+		key := s.key(session.ID)
+		s.keepMetadata(tx, sessionData, key)
+		return s.layout.Put(tx, key, sessionData)
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	// The creation time is kept in the database from now on.
+	delete(session.Values, createdAtKey)
+	return nil
+}
+
+// keepMetadata copies the creation time and the impersonation of
+// the existing session data of the key to the session data. Nothing is
+// copied from expired or revoked session data, which must not revoke or
+// impersonate the new one.
+func (s *Store) keepMetadata(tx *bolt.Tx, sessionData *protobuf.Session, key []byte) {
+	data := s.layout.Get(tx, key)
+	if data == nil {
+		return
+	}
+	prev, err := shared.Session(data)
+	if err != nil || shared.ExpiredAt(prev, s.config.Clock.Now(), s.config.ClockSkew) || shared.Revoked(tx.Bucket(s.config.DBOptions.MetaBucketName), prev) {
+		return
+	}
+	if prev.CreatedAt != nil {
+		sessionData.CreatedAt = prev.CreatedAt
+	}
+	sessionData.ParentID = prev.ParentID
+	sessionData.Impersonator = prev.Impersonator
+	sessionData.ImpersonatedAt = prev.ImpersonatedAt
+}
+
+// view executes the function within a read-only transaction.
+func (s *Store) view(fn func(tx *bolt.Tx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.View(fn)
+}
+
+// update executes the function within a read-write transaction.
+func (s *Store) update(fn func(tx *bolt.Tx) error) error {
+	s.wmu.RLock()
+	defer s.wmu.RUnlock()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Update(fn)
+}
+
+// key returns the database key of the session ID.
+func (s *Store) key(id string) []byte {
+	if s.config.DBOptions.IDHashKey == nil {
+		return []byte(id)
+	}
+	return shared.HashID(s.config.DBOptions.IDHashKey, []byte(id))
+}
+
+// copyBytes returns a copy of the byte slice. Nil is returned
+// if the byte slice is nil.
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
 }
 
 // New creates and returns a session store.
 func New(db *bolt.DB, config Config, keyPairs ...[]byte) (*Store, error) {
 	config.setDefault()
+	types, err := keyTypes(config.Keys)
+	if err != nil {
+		return nil, err
+	}
 	store := &Store{
-		codecs: securecookie.CodecsFromPairs(keyPairs...),
-		config: config,
-		db:     db,
+		codecs:   securecookie.CodecsFromPairs(keyPairs...),
+		config:   config,
+		db:       db,
+		layout:   config.DBOptions.layout(),
+		keyTypes: types,
 	}
-	err := db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(config.DBOptions.BucketName)
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := store.layout.Init(tx); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(config.DBOptions.MetaBucketName)
 		return err
 	})
 	if err != nil {
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -266,8 +267,8 @@ func TestStore_load(t *testing.T) {
 		t.Error(err)
 	}
 	_, err = str.load(session)
-	if err == nil || err.Error() != "proto: protobuf.Session: wiretype end group for non-group" {
-		t.Errorf(`str.load should return an error "%s" (actual: %s)`, "proto: protobuf.Session: wiretype end group for non-group", err)
+	if !errors.Is(err, ErrCorruptSession) {
+		t.Errorf(`str.load should return an error "%s" (actual: %s)`, ErrCorruptSession, err)
 	}
 
 	// When the target session data is expired
@@ -287,6 +288,187 @@ func TestStore_load(t *testing.T) {
 	}
 }
 
+func TestStore_hashedIDs(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	bucketName := []byte(fmt.Sprintf("hashedIDsTest-%d", time.Now().UnixNano()))
+
+	// Save a session under the raw ID.
+	str, err := New(db, Config{DBOptions: Options{BucketName: bucketName}}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	session, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	session.Values["foo"] = "bar"
+	if err := str.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Error(err)
+	}
+
+	// When the session is not migrated
+	str, err = New(db, Config{DBOptions: Options{BucketName: bucketName, IDHashKey: []byte("hash-key")}}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	if exists, err := str.load(session); err != nil || exists {
+		t.Errorf("str.load should return false (actual: %+v, %+v)", exists, err)
+	}
+
+	// When the session is migrated
+	str.config.DBOptions.MigrateUnhashedIDs = true
+	if exists, err := str.load(session); err != nil || !exists {
+		t.Errorf("str.load should return true (actual: %+v, %+v)", exists, err)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketName)
+		if bucket.Get([]byte(session.ID)) != nil {
+			t.Error("the raw ID should be removed")
+		}
+		if bucket.Get(shared.HashID([]byte("hash-key"), []byte(session.ID))) == nil {
+			t.Error("the hashed ID should be stored")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// When the session is deleted
+	if err := str.delete(session); err != nil {
+		t.Error(err)
+	}
+	if exists, err := str.load(session); err != nil || exists {
+		t.Errorf("str.load should return false (actual: %+v, %+v)", exists, err)
+	}
+}
+
+func TestStore_expiryIndex(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	bucketName := []byte(fmt.Sprintf("expiryIndexTest-%d", time.Now().UnixNano()))
+	indexName := shared.ExpiryIndexBucketName(bucketName)
+
+	// When the index is built for the existing sessions
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(bucketName)
+		if err != nil {
+			return err
+		}
+		return shared.PutSession(bucket, nil, []byte("existing"), shared.NewSession([]byte{}, 60))
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	str, err := New(db, Config{DBOptions: Options{BucketName: bucketName}}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	indexEntries := func() int {
+		var n int
+		db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket(indexName).Stats().KeyN
+			return nil
+		})
+		return n
+	}
+	if n := indexEntries(); n != 1 {
+		t.Errorf("the index should have 1 entry (actual: %d)", n)
+	}
+
+	// When a session is saved
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	session, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	if err := str.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Error(err)
+	}
+	if err := str.Save(req, httptest.NewRecorder(), session); err != nil {
+		t.Error(err)
+	}
+	if n := indexEntries(); n != 2 {
+		t.Errorf("the index should have 2 entries (actual: %d)", n)
+	}
+
+	// When a session is deleted
+	if err := str.delete(session); err != nil {
+		t.Error(err)
+	}
+	if n := indexEntries(); n != 1 {
+		t.Errorf("the index should have 1 entry (actual: %d)", n)
+	}
+}
+
+func TestStore_partitioned(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	bucketName := []byte(fmt.Sprintf("partitionedTest-%d", time.Now().UnixNano()))
+	str, err := New(db, Config{DBOptions: Options{BucketName: bucketName, Partition: time.Hour}}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	session, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	session.Values["foo"] = "bar"
+	w := httptest.NewRecorder()
+	if err := str.Save(req, w, session); err != nil {
+		t.Error(err)
+	}
+
+	req.Header.Set("Cookie", w.Header().Get("Set-Cookie"))
+	loaded, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	if loaded.IsNew || loaded.Values["foo"] != "bar" {
+		t.Errorf("the session should be loaded (actual: %+v)", loaded)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketName).Get([]byte(session.ID)) != nil {
+			t.Error("the session should not be stored in the bucket itself")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if err := str.delete(session); err != nil {
+		t.Error(err)
+	}
+	if exists, err := str.load(session); err != nil || exists {
+		t.Errorf("str.load should return false (actual: %+v, %+v)", exists, err)
+	}
+}
+
 func TestSession_delete(t *testing.T) {
 	db, err := bolt.Open("./sessions.db", 0666, nil)
 	if err != nil {
@@ -604,3 +786,97 @@ func BenchmarkStore_Save_delete(b *testing.B) {
 		}
 	}
 }
+
+func TestStore_keepExpired(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	bucketName := []byte(fmt.Sprintf("keepExpiredTest-%d", time.Now().UnixNano()))
+	str, err := New(db, Config{DBOptions: Options{BucketName: bucketName, KeepExpired: true}}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	impersonator := "admin"
+	err = db.Update(func(tx *bolt.Tx) error {
+		expired := shared.NewSession([]byte{}, -1)
+		expired.Impersonator = &impersonator
+		return str.layout.Put(tx, []byte("expired"), expired)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	session := sessions.NewSession(str, "test")
+	session.ID = "expired"
+	if ok, err := str.load(session); ok || err != nil {
+		t.Errorf("the expired session should not be loaded (actual: %t, %v)", ok, err)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		if str.layout.Get(tx, []byte("expired")) == nil {
+			t.Error("the expired session should be kept")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// When a session is saved over the expired session data
+	session.ID = "expired"
+	session.Options = &sessions.Options{MaxAge: 60}
+	if err := str.save(session); err != nil {
+		t.Error(err)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		saved, err := shared.Session(str.layout.Get(tx, []byte("expired")))
+		if err != nil {
+			return err
+		}
+		if saved.Impersonator != nil {
+			t.Errorf("the impersonation of the expired session data should not be kept (actual: %s)", saved.GetImpersonator())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStore_Lookup(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	bucketName := []byte(fmt.Sprintf("lookupTest-%d", time.Now().UnixNano()))
+	str, err := New(db, Config{DBOptions: Options{BucketName: bucketName, IDHashKey: []byte("hash-key")}}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := str.layout.Put(tx, str.key("expired"), shared.NewSession([]byte("test"), -1)); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketName).Put(str.key("invalid"), []byte("test"))
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// When the session data exists
+	if session, ok, err := str.Lookup("expired"); err != nil || !ok || string(session.GetValues()) != "test" {
+		t.Errorf("str.Lookup should return the session data (actual: %s, %t, %v)", session.String(), ok, err)
+	}
+	// When the session data does not exist
+	if _, ok, err := str.Lookup("none"); err != nil || ok {
+		t.Errorf("str.Lookup should return false (actual: %t, %v)", ok, err)
+	}
+	// When the session data is invalid
+	if _, ok, err := str.Lookup("invalid"); !errors.Is(err, ErrCorruptSession) || !ok {
+		t.Errorf("str.Lookup should return an error which wraps ErrCorruptSession (actual: %t, %v)", ok, err)
+	}
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/yosssi/boltstore/shared"
+)
+
+// Transport represents a way to carry an encoded session ID
+// between a client and the server.
+type Transport interface {
+	// Get returns the encoded session ID of the given name from the request.
+	Get(r *http.Request, name string) (string, bool)
+	// Set sends the encoded session ID of the given name in the response.
+	// An empty value means that the session is deleted.
+	Set(w http.ResponseWriter, name, value string, options *sessions.Options)
+}
+
+// CookieTransport carries the session ID in a cookie.
+type CookieTransport struct{}
+
+// Get returns the value of the cookie of the given name.
+func (t CookieTransport) Get(r *http.Request, name string) (string, bool) {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	return c.Value, true
+}
+
+// Set adds a Set-Cookie header to the response.
+func (t CookieTransport) Set(w http.ResponseWriter, name, value string, options *sessions.Options) {
+	http.SetCookie(w, sessions.NewCookie(name, value, options))
+}
+
+// HeaderTransport carries the session ID in a custom header
+// of both the request and the response. A header carries only one
+// session, so Name should be set if sessions of several names are used.
+type HeaderTransport struct {
+	// Header represents the name of the header.
+	Header string
+	// Name represents the name of the session carried by the header.
+	// Sessions of any name are carried if it is empty.
+	Name string
+}
+
+// Get returns the value of the header.
+func (t HeaderTransport) Get(r *http.Request, name string) (string, bool) {
+	if !carries(t.Name, name) {
+		return "", false
+	}
+	value := r.Header.Get(t.Header)
+	return value, value != ""
+}
+
+// Set sets the header to the response.
+func (t HeaderTransport) Set(w http.ResponseWriter, name, value string, options *sessions.Options) {
+	if carries(t.Name, name) {
+		w.Header().Set(t.Header, value)
+	}
+}
+
+// BearerTransport reads the session ID from an "Authorization: Bearer"
+// request header and returns it in a response header. A request carries
+// only one bearer token, so Name should be set if sessions of several
+// names are used.
+type BearerTransport struct {
+	// ResponseHeader represents the name of the response header.
+	// shared.DefaultResponseHeader is used if it is empty.
+	ResponseHeader string
+	// Name represents the name of the session carried by the token.
+	// Sessions of any name are carried if it is empty.
+	Name string
+}
+
+// Get returns the bearer token of the Authorization header.
+func (t BearerTransport) Get(r *http.Request, name string) (string, bool) {
+	if !carries(t.Name, name) {
+		return "", false
+	}
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= len("Bearer ") || !strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(auth[len("Bearer "):]), true
+}
+
+// Set sets the response header.
+func (t BearerTransport) Set(w http.ResponseWriter, name, value string, options *sessions.Options) {
+	if !carries(t.Name, name) {
+		return
+	}
+	header := t.ResponseHeader
+	if header == "" {
+		header = shared.DefaultResponseHeader
+	}
+	w.Header().Set(header, value)
+}
+
+// MultiTransport reads the session ID from the first transport which
+// carries it and sends it through all transports.
+type MultiTransport []Transport
+
+// Get returns the value of the first transport which carries it.
+func (t MultiTransport) Get(r *http.Request, name string) (string, bool) {
+	for _, tr := range t {
+		if value, ok := tr.Get(r, name); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Set sends the value through all transports.
+func (t MultiTransport) Set(w http.ResponseWriter, name, value string, options *sessions.Options) {
+	for _, tr := range t {
+		tr.Set(w, name, value, options)
+	}
+}
+
+// carries checks if a transport for the session name carries the session
+// of the given name.
+func carries(transportName, name string) bool {
+	return transportName == "" || transportName == name
+}
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/gorilla/sessions"
+	"github.com/yosssi/boltstore/shared"
+)
+
+func TestCookieTransport(t *testing.T) {
+	tr := CookieTransport{}
+	w := httptest.NewRecorder()
+	tr.Set(w, "test", "value", &sessions.Options{MaxAge: 60})
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := tr.Get(req, "test"); ok {
+		t.Error("CookieTransport.Get should return false when there is no cookie")
+	}
+	req.Header.Set("Cookie", w.Header().Get("Set-Cookie"))
+	if value, ok := tr.Get(req, "test"); !ok || value != "value" {
+		t.Errorf("CookieTransport.Get should return %s (actual: %s)", "value", value)
+	}
+}
+
+func TestHeaderTransport(t *testing.T) {
+	tr := HeaderTransport{Header: "X-Session"}
+	w := httptest.NewRecorder()
+	tr.Set(w, "test", "value", nil)
+	if value := w.Header().Get("X-Session"); value != "value" {
+		t.Errorf("the response header should be %s (actual: %s)", "value", value)
+	}
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := tr.Get(req, "test"); ok {
+		t.Error("HeaderTransport.Get should return false when there is no header")
+	}
+	req.Header.Set("X-Session", "value")
+	if value, ok := tr.Get(req, "test"); !ok || value != "value" {
+		t.Errorf("HeaderTransport.Get should return %s (actual: %s)", "value", value)
+	}
+
+	// When the name is set
+	tr.Name = "test"
+	if _, ok := tr.Get(req, "other"); ok {
+		t.Error("HeaderTransport.Get should return false for the other name")
+	}
+	w = httptest.NewRecorder()
+	tr.Set(w, "other", "other", nil)
+	if value := w.Header().Get("X-Session"); value != "" {
+		t.Errorf("the response header should not be set for the other name (actual: %s)", value)
+	}
+}
+
+func TestBearerTransport(t *testing.T) {
+	tr := BearerTransport{}
+	w := httptest.NewRecorder()
+	tr.Set(w, "test", "value", nil)
+	if value := w.Header().Get(shared.DefaultResponseHeader); value != "value" {
+		t.Errorf("the response header should be %s (actual: %s)", "value", value)
+	}
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	req.Header.Set("Authorization", "Basic value")
+	if _, ok := tr.Get(req, "test"); ok {
+		t.Error("BearerTransport.Get should return false when the scheme is not Bearer")
+	}
+	req.Header.Set("Authorization", "bearer value")
+	if value, ok := tr.Get(req, "test"); !ok || value != "value" {
+		t.Errorf("BearerTransport.Get should return %s (actual: %s)", "value", value)
+	}
+
+	// When the name is set
+	tr.Name = "test"
+	if _, ok := tr.Get(req, "other"); ok {
+		t.Error("BearerTransport.Get should return false for the other name")
+	}
+	w = httptest.NewRecorder()
+	tr.Set(w, "other", "other", nil)
+	if value := w.Header().Get(shared.DefaultResponseHeader); value != "" {
+		t.Errorf("the response header should not be set for the other name (actual: %s)", value)
+	}
+}
+
+func TestMultiTransport(t *testing.T) {
+	tr := MultiTransport{CookieTransport{}, BearerTransport{}}
+	w := httptest.NewRecorder()
+	tr.Set(w, "test", "value", &sessions.Options{MaxAge: 60})
+	if w.Header().Get("Set-Cookie") == "" || w.Header().Get(shared.DefaultResponseHeader) != "value" {
+		t.Errorf("MultiTransport.Set should set all transports (actual: %+v)", w.Header())
+	}
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := tr.Get(req, "test"); ok {
+		t.Error("MultiTransport.Get should return false when no transport carries the value")
+	}
+	req.Header.Set("Authorization", "Bearer value")
+	if value, ok := tr.Get(req, "test"); !ok || value != "value" {
+		t.Errorf("MultiTransport.Get should return %s (actual: %s)", "value", value)
+	}
+
+	// When the bearer token carries the session of another name
+	tr = MultiTransport{BearerTransport{Name: "api"}, CookieTransport{}}
+	req.AddCookie(&http.Cookie{Name: "test", Value: "cookie"})
+	if value, ok := tr.Get(req, "test"); !ok || value != "cookie" {
+		t.Errorf("MultiTransport.Get should return %s (actual: %s)", "cookie", value)
+	}
+}
+
+func TestStore_bearerTransport(t *testing.T) {
+	db, err := bolt.Open("./sessions.db", 0666, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	defer db.Close()
+
+	str, err := New(db, Config{Transport: BearerTransport{}}, []byte("secret-key"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	req, err := http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	session, err := str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	session.Values["foo"] = "bar"
+	w := httptest.NewRecorder()
+	if err := str.Save(req, w, session); err != nil {
+		t.Error(err)
+	}
+	token := w.Header().Get(shared.DefaultResponseHeader)
+	if token == "" || w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("the session ID should be returned only in the response header (actual: %+v)", w.Header())
+	}
+
+	req, err = http.NewRequest("GET", "http://localhost:3000/", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	session, err = str.New(req, "test")
+	if err != nil {
+		t.Error(err)
+	}
+	if session.IsNew || session.Values["foo"] != "bar" {
+		t.Errorf("the session should be loaded (actual: %+v)", session)
+	}
+}